@@ -0,0 +1,107 @@
+package lrucache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// autoTuner resizes the cache toward a target hit ratio
+type autoTuner struct {
+	min, max int
+	target   float64
+	// stats counters at the previous step
+	hits, misses uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WithAutoTune resizes the cache every interval to keep the hit ratio of the lookups made
+// since the previous interval near target, within [min, max] entries, or total weight.
+// The size grows by a tenth of the range, at least 1, while the ratio is below target,
+// and shrinks by a quarter when the ratio reaches target with the cache less than half
+// full, so the shrinking never evicts. An interval without lookups leaves the size alone.
+// The initial size is brought within the range. Close stops the background goroutine.
+// It does nothing if interval <= 0, min <= 0 or max < min.
+func WithAutoTune(min, max int, target float64, interval time.Duration) Option {
+	return func(c *LruCache) {
+		if interval <= 0 || min <= 0 || max < min {
+			return
+		}
+		if c.size < min {
+			c.size = min
+		} else if c.size > max {
+			c.size = max
+		}
+		a := &autoTuner{
+			min:    min,
+			max:    max,
+			target: target,
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		c.autoTune = a
+		go a.run(c, interval)
+	}
+}
+
+// run tunes c every interval until stopped
+func (a *autoTuner) run(c *LruCache, interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.step(c)
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// step resizes c from the hit ratio since the previous step, it returns the new size
+func (a *autoTuner) step(c *LruCache) int {
+	hits, misses := atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+	dh, dm := hits-a.hits, misses-a.misses
+	if hits < a.hits || misses < a.misses {
+		// the stats were reset
+		dh, dm = hits, misses
+	}
+	a.hits, a.misses = hits, misses
+	size := c.Cap()
+	if dh+dm == 0 {
+		return size
+	}
+	ratio := float64(dh) / float64(dh+dm)
+	newSize := size
+	if ratio < a.target {
+		step := (a.max - a.min) / 10
+		if step < 1 {
+			step = 1
+		}
+		newSize = size + step
+		if newSize > a.max {
+			newSize = a.max
+		}
+	} else if c.Weight() < size/2 {
+		newSize = size - size/4
+		if newSize < a.min {
+			newSize = a.min
+		}
+	}
+	if newSize != size {
+		c.Resize(newSize)
+	}
+	return newSize
+}
+
+// close stops the background goroutine, it is safe to call it more than once
+func (a *autoTuner) close() {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+		<-a.done
+	})
+}
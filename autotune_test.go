@@ -0,0 +1,76 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_AutoTune(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil, WithAutoTune(10, 30, 0.9, time.Hour))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+	if l.Cap() != 10 {
+		t.Fatalf("the size should be brought within the range: %d", l.Cap())
+	}
+	a := l.autoTune
+	if size := a.step(l); size != 10 {
+		t.Fatalf("no lookups should keep the size: %d", size)
+	}
+
+	// a poor ratio grows the size up to max
+	for i := 0; i < 40; i++ {
+		l.Get(i)
+	}
+	if size := a.step(l); size != 12 || l.Cap() != 12 {
+		t.Fatalf("bad size: %d", size)
+	}
+	for i := 0; i < 20; i++ {
+		l.Get(i)
+		a.step(l)
+	}
+	if l.Cap() != 30 {
+		t.Fatalf("the size should be capped: %d", l.Cap())
+	}
+
+	// a good ratio with a low fill shrinks it down to min
+	l.Put(1, 1, Expired)
+	for i := 0; i < 10; i++ {
+		l.Get(1)
+	}
+	if size := a.step(l); size != 23 {
+		t.Fatalf("bad size: %d", size)
+	}
+	for i := 0; i < 10; i++ {
+		l.Get(1)
+		a.step(l)
+	}
+	if l.Cap() != 10 || !l.Contains(1) {
+		t.Fatalf("the size should be at min: %d", l.Cap())
+	}
+
+	// a good ratio with a high fill keeps the size
+	for i := 0; i < 10; i++ {
+		l.Put(i, i, Expired)
+		l.Get(i)
+	}
+	if size := a.step(l); size != 10 {
+		t.Fatalf("bad size: %d", size)
+	}
+}
+
+func TestLRU_AutoTuneBackground(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil, WithAutoTune(2, 100, 0.5, 5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	waitFor(t, func() bool {
+		l.Get(0)
+		return l.Cap() > 2
+	})
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,74 @@
+package lrucache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time, the cache uses it to set and check the expiry deadlines
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the default Clock, using time.Now
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// WithClock makes the cache use clock instead of the system time, for example
+// a FakeClock to test the expiry without sleeping. The janitor still runs
+// on real time, it only checks the deadlines with clock.
+func WithClock(clock Clock) Option {
+	return func(c *LruCache) {
+		c.clock = clock
+	}
+}
+
+// FakeClock is a Clock which only moves when told to, it is safe for concurrent use
+type FakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewFakeClock creates a FakeClock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock
+func (f *FakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d
+func (f *FakeClock) Advance(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the clock to now
+func (f *FakeClock) Set(now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = now
+}
+
+// now returns the current time of the cache clock
+func (c *LruCache) now() time.Time {
+	return c.clock.Now()
+}
+
+// expired returns true if e is expired by the cache clock or stored before the last Invalidate,
+// the clock is not read for the entries which never expire
+func (c *LruCache) expired(e *entry) bool {
+	if e.epoch != c.epoch {
+		return true
+	}
+	if c.noExpiry || e.ttl == nil {
+		return false
+	}
+	return e.expiredAt(c.now())
+}
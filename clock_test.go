@@ -0,0 +1,267 @@
+package lrucache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_FakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, time.Minute, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, time.Hour)
+
+	clock.Advance(59 * time.Second)
+	if _, ok := l.Get(1); !ok {
+		t.Fatalf("1 should not be expired")
+	}
+	if ttl, _ := l.TTL(1); ttl != time.Second {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should be expired")
+	}
+	if e := l.Entries(); len(e) != 1 || e[0].Key != 2 || e[0].TTL != time.Hour-61*time.Second {
+		t.Fatalf("bad entries: %+v", e)
+	}
+
+	clock.Advance(time.Hour)
+	if l.Contains(2) || l.LenLive() != 0 {
+		t.Fatalf("2 should be expired")
+	}
+}
+
+func TestLRU_FakeClockSliding(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, time.Minute, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSlidingExpiration(true)
+	l.Put(1, 1, 0)
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(50 * time.Second)
+		if _, ok := l.Get(1); !ok {
+			t.Fatalf("1 should be kept alive")
+		}
+	}
+	clock.Advance(61 * time.Second)
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should be expired")
+	}
+}
+
+func TestLRU_MaxTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock), WithMaxTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Hour)
+	l.Put(2, 2, 0)
+	l.Put(3, 3, time.Second)
+	l.Put(4, 4, time.Hour)
+	l.Touch(4, time.Hour)
+
+	for _, k := range []int{1, 2, 4} {
+		if ttl, ok := l.TTL(k); !ok || ttl != time.Minute {
+			t.Fatalf("bad ttl for %v: %v", k, ttl)
+		}
+	}
+	if ttl, _ := l.TTL(3); ttl != time.Second {
+		t.Fatalf("shorter ttl should be kept: %v", ttl)
+	}
+
+	clock.Advance(time.Minute + time.Second)
+	if l.LenLive() != 0 {
+		t.Fatalf("all entries should be expired: %v", l.Keys())
+	}
+}
+
+func TestLRU_ExtendTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Minute)
+	l.Put(2, 2, 0)
+	l.Put(3, 3, time.Second)
+
+	clock.Advance(30 * time.Second)
+	if !l.ExtendTTL(1, time.Minute) {
+		t.Fatalf("1 should be extended")
+	}
+	if ttl, _ := l.TTL(1); ttl != 90*time.Second {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if !l.ExtendTTL(2, time.Minute) {
+		t.Fatalf("2 should be extended")
+	}
+	if ttl, _ := l.TTL(2); ttl != time.Minute {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if l.ExtendTTL(3, time.Minute) || l.ExtendTTL(4, time.Minute) {
+		t.Fatalf("expired or missing keys should not be extended")
+	}
+	if keys := l.Keys(); len(keys) != 2 || keys[0] != 1 {
+		t.Fatalf("recent-ness should not change: %v", keys)
+	}
+}
+
+func TestLRU_ExtendTTLMaxTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock), WithMaxTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 30*time.Second)
+	l.ExtendTTL(1, time.Hour)
+	if ttl, _ := l.TTL(1); ttl != time.Minute {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+}
+
+func TestLRU_MaxTTLSinceStored(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock), WithMaxTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 30*time.Second)
+	l.Put(2, 2, 30*time.Second)
+	l.Put(3, 3, 30*time.Second)
+	l.SetSlidingExpiration(true)
+	// the cap counts from the Put, whatever keeps the keys alive meanwhile
+	for i := 0; i < 2; i++ {
+		clock.Advance(20 * time.Second)
+		l.Touch(1, 30*time.Second)
+		l.ExtendTTL(2, 30*time.Second)
+		l.Get(3)
+	}
+	if ttl, _ := l.TTL(1); ttl != 20*time.Second {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	clock.Advance(21 * time.Second)
+	for i := 1; i <= 3; i++ {
+		if l.Touch(i, 30*time.Second) || l.ExtendTTL(i, time.Hour) || l.Contains(i) {
+			t.Fatalf("%d should be expired past the max ttl", i)
+		}
+	}
+	l.Put(1, 1, 30*time.Second)
+	if ttl, _ := l.TTL(1); ttl != 30*time.Second {
+		t.Fatalf("a new Put should restart the cap: %v", ttl)
+	}
+}
+
+func TestLRU_NoExpiration(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, time.Minute, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, -time.Second)
+	l.Put(3, 3, NoExpiration)
+	l.PutPermanent(4, 4)
+	l.Put(5, 5, time.Minute)
+	l.Touch(5, NoExpiration)
+
+	for _, k := range []int{1, 2} {
+		if ttl, _ := l.TTL(k); ttl != time.Minute {
+			t.Fatalf("%v should use the cache ttl: %v", k, ttl)
+		}
+	}
+	for _, k := range []int{3, 4, 5} {
+		if ttl, _ := l.TTL(k); ttl != NoExpiration {
+			t.Fatalf("%v should never expire: %v", k, ttl)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 3 || keys[1] != 4 || keys[2] != 5 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLRU_SetDefaultTTL(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, time.Minute, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.SetDefaultTTL(time.Second)
+	if l.DefaultTTL() != time.Second {
+		t.Fatalf("bad default ttl: %v", l.DefaultTTL())
+	}
+	l.Put(2, 2, 0)
+	l.Put(3, 3, time.Hour)
+
+	if ttl, _ := l.TTL(1); ttl != time.Minute {
+		t.Fatalf("existing entries should keep their deadline: %v", ttl)
+	}
+	if ttl, _ := l.TTL(2); ttl != time.Second {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+	if ttl, _ := l.TTL(3); ttl != time.Hour {
+		t.Fatalf("bad ttl: %v", ttl)
+	}
+}
+
+func TestLRU_WithoutExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, time.Second, nil, WithClock(clock), WithMaxTTL(time.Minute), WithoutExpiry())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, time.Millisecond)
+	l.ExtendTTL(1, time.Second)
+	clock.Advance(time.Hour)
+	for i := 1; i <= 2; i++ {
+		if v, ok := l.Get(i); !ok || v != i {
+			t.Fatalf("%d should never expire", i)
+		}
+		if ttl, ok := l.TTL(i); !ok || ttl != NoExpiration {
+			t.Fatalf("bad ttl: %v %v", ttl, ok)
+		}
+	}
+	if l.FlushExpired() != 0 || l.Snapshot().Len() != 2 {
+		t.Fatalf("nothing should expire")
+	}
+}
+
+// countingClock is a FakeClock counting its reads
+type countingClock struct {
+	*FakeClock
+	reads int32
+}
+
+func (c *countingClock) Now() time.Time {
+	atomic.AddInt32(&c.reads, 1)
+	return c.FakeClock.Now()
+}
+
+func TestLRU_WithoutExpiryClockReads(t *testing.T) {
+	clock := &countingClock{FakeClock: NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}
+	l, err := NewLRUCache(2, time.Second, nil, WithClock(clock), WithoutExpiry())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	before := atomic.LoadInt32(&clock.reads)
+	for i := 0; i < 100; i++ {
+		l.Get(1)
+	}
+	if n := atomic.LoadInt32(&clock.reads) - before; n != 0 {
+		t.Fatalf("the hits should not read the clock: %v", n)
+	}
+}
@@ -0,0 +1,37 @@
+package lrucache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotInt64 is returned by Increment for a value which is not an int64
+var ErrNotInt64 = errors.New("lrucache: value is not an int64")
+
+// Increment adds delta to the int64 value of key, starting from 0 if the key is missing
+// or expired, and stores the result with the specified maximum duration, moving it to the front.
+// It returns the new value, or ErrNotInt64 without changing anything if the value is not an int64.
+// A new key rejected by WithFullPolicy or refused by WithAdmissionPolicy is not stored and
+// ErrCacheFull is returned, so the increment is not lost silently.
+func (c *LruCache) Increment(key interface{}, delta int64, ttl time.Duration) (newValue int64, err error) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent, ok := c.cache[key]; ok {
+		kv := ent.Value.(*entry)
+		if !c.expired(kv) && !kv.miss {
+			n, ok := kv.value.(int64)
+			if !ok {
+				return 0, ErrNotInt64
+			}
+			newValue = n
+		}
+	}
+	newValue += delta
+	if _, err := c.add(&entry{key: key, value: newValue, lifetime: c.lifetime(ttl)}); err != nil {
+		return 0, err
+	}
+	if _, ok := c.cache[key]; !ok {
+		return 0, ErrCacheFull
+	}
+	return newValue, nil
+}
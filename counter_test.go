@@ -0,0 +1,89 @@
+package lrucache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU_Increment(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if n, err := l.Increment(1, 5, Expired); err != nil || n != 5 {
+		t.Fatalf("bad increment: %v, %v", n, err)
+	}
+	if n, err := l.Increment(1, -2, Expired); err != nil || n != 3 {
+		t.Fatalf("bad increment: %v, %v", n, err)
+	}
+	if v, _ := l.Get(1); v != int64(3) {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	l.Put(2, "two", Expired)
+	if _, err := l.Increment(2, 1, Expired); err != ErrNotInt64 {
+		t.Fatalf("err: %v", err)
+	}
+	if v, _ := l.Peek(2); v != "two" {
+		t.Fatalf("value should not change: %v", v)
+	}
+
+	l.Put(3, int64(10), 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if n, err := l.Increment(3, 1, Expired); err != nil || n != 1 {
+		t.Fatalf("expired value should restart from 0: %v, %v", n, err)
+	}
+}
+
+func TestLRU_IncrementRejected(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil, WithFullPolicy(PolicyReject))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	for i := 0; i < 2; i++ {
+		if n, err := l.Increment("c", 5, Expired); err != ErrCacheFull || n != 0 {
+			t.Fatalf("a rejected increment should fail: %v, %v", n, err)
+		}
+	}
+	if l.Contains("c") {
+		t.Fatalf("c should not be stored")
+	}
+
+	l, err = NewLRUCache(1, Expired, nil, WithAdmissionPolicy(true))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	for i := 0; i < 4; i++ {
+		l.Get(1)
+	}
+	if _, err := l.Increment("c", 5, Expired); err != ErrCacheFull || l.Contains("c") {
+		t.Fatalf("a refused increment should fail: %v", err)
+	}
+}
+
+func TestLRU_IncrementConcurrent(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				l.Increment("n", 1, Expired)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := l.Get("n"); v != int64(800) {
+		t.Fatalf("bad count: %v", v)
+	}
+}
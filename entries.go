@@ -0,0 +1,133 @@
+package lrucache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxStringValue is the length beyond which String truncates a value
+const maxStringValue = 32
+
+// EntryInfo describes a cache entry for debugging
+type EntryInfo struct {
+	Key   interface{}
+	Value interface{}
+	// ExpiresAt is nil if the entry never expires
+	ExpiresAt *time.Time
+	// TTL is the remaining lifetime, NoExpiration if the entry never expires
+	TTL     time.Duration
+	Expired bool
+}
+
+// Entries returns a snapshot of the entries from oldest to newest, including the
+// expired ones not removed yet. Negative entries stored by PutMiss are left out.
+func (c *LruCache) Entries() []EntryInfo {
+	c.readLock()
+	defer c.lock.RUnlock()
+	entries := make([]EntryInfo, 0, len(c.cache))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if kv.miss {
+			continue
+		}
+		entries = append(entries, kv.info(c.now(), c.epoch))
+	}
+	return entries
+}
+
+// ExpiringSoon returns the live entries sorted by expiry deadline, soonest first, with the
+// entries which never expire last. Entries with the same deadline are ordered oldest first.
+// It copies the entries under the read lock and sorts them after releasing it, in O(n log n).
+func (c *LruCache) ExpiringSoon() []EntryInfo {
+	c.readLock()
+	entries := make([]EntryInfo, 0, len(c.cache))
+	now := c.now()
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if kv.miss || kv.epoch != c.epoch || kv.expiredAt(now) {
+			continue
+		}
+		entries = append(entries, kv.info(now, c.epoch))
+	}
+	c.lock.RUnlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].ExpiresAt, entries[j].ExpiresAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	return entries
+}
+
+// NextExpiry returns the earliest expiry deadline among the entries, including the negative
+// ones, to schedule a single timer running FlushExpired. ok is false if no entry expires.
+// An expired entry not removed yet gives a time in the past, the entries made stale by
+// Invalidate give now. It scans every entry under the read lock, in O(n).
+func (c *LruCache) NextExpiry() (next time.Time, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	now := c.now()
+	for _, ent := range c.cache {
+		kv := ent.Value.(*entry)
+		deadline := kv.ttl
+		if kv.epoch != c.epoch {
+			deadline = &now
+		}
+		if deadline != nil && (!ok || deadline.Before(next)) {
+			next, ok = *deadline, true
+		}
+	}
+	return next, ok
+}
+
+// info returns the EntryInfo of e at now in the cache epoch, the entries of an earlier epoch are expired
+func (e *entry) info(now time.Time, epoch uint64) EntryInfo {
+	info := EntryInfo{
+		Key:     e.key,
+		Value:   e.value,
+		TTL:     e.remaining(now),
+		Expired: e.epoch != epoch || e.expiredAt(now),
+	}
+	if e.ttl != nil {
+		expiresAt := *e.ttl
+		info.ExpiresAt = &expiresAt
+	}
+	if info.Expired {
+		info.TTL = 0
+	}
+	return info
+}
+
+// String describes the cache and its entries from newest to oldest for debugging,
+// like "LruCache(len=2/16, ttl=5s) [k1=v1(exp 2s), k2=v2(no-exp)]".
+// It doesn't update the recent-ness.
+func (c *LruCache) String() string {
+	c.readLock()
+	defer c.lock.RUnlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "LruCache(len=%d/%d, ttl=%v) [", c.evictList.Len(), c.size, c.ttl)
+	now := c.now()
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		kv := ent.Value.(*entry)
+		if ent != c.evictList.Front() {
+			b.WriteString(", ")
+		}
+		value := "<miss>"
+		if !kv.miss {
+			value = fmt.Sprint(kv.value)
+			if len(value) > maxStringValue {
+				value = value[:maxStringValue] + "..."
+			}
+		}
+		switch info := kv.info(now, c.epoch); {
+		case info.Expired:
+			fmt.Fprintf(&b, "%v=%s(expired)", kv.key, value)
+		case info.TTL == NoExpiration:
+			fmt.Fprintf(&b, "%v=%s(no-exp)", kv.key, value)
+		default:
+			fmt.Fprintf(&b, "%v=%s(exp %v)", kv.key, value, info.TTL)
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
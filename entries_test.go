@@ -0,0 +1,132 @@
+package lrucache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRU_Entries(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(0, 0, 10*time.Millisecond)
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	entries := l.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("bad entries: %v", entries)
+	}
+	for i, e := range entries {
+		if e.Key != i || e.Value != i {
+			t.Fatalf("entries should be ordered oldest to newest: %v", entries)
+		}
+	}
+	if !entries[0].Expired || entries[0].ExpiresAt == nil || entries[0].TTL != 0 {
+		t.Fatalf("0 should be expired: %+v", entries[0])
+	}
+	if entries[1].Expired || entries[1].ExpiresAt == nil || entries[1].TTL <= 0 {
+		t.Fatalf("1 should be live: %+v", entries[1])
+	}
+	if entries[2].Expired || entries[2].ExpiresAt != nil || entries[2].TTL != NoExpiration {
+		t.Fatalf("2 should never expire: %+v", entries[2])
+	}
+}
+
+func TestLRU_String(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(16, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put("k1", 1, time.Second)
+	l.Put("k2", strings.Repeat("v", 40), 5*time.Second)
+	l.PutMiss("k3", time.Minute)
+	l.Put("k4", nil, 0)
+	clock.Advance(2 * time.Second)
+
+	want := "LruCache(len=4/16, ttl=0s) [k4=<nil>(no-exp), k3=<miss>(exp 58s), k2=" +
+		strings.Repeat("v", 32) + "...(exp 3s), k1=1(expired)]"
+	if s := l.String(); s != want {
+		t.Fatalf("bad string: %s", s)
+	}
+	if _, ok := l.Peek("k1"); ok {
+		t.Fatalf("k1 should be expired")
+	}
+	empty, _ := NewLRUCache(2, 0, nil)
+	if s := empty.String(); s != "LruCache(len=0/2, ttl=0s) []" {
+		t.Fatalf("bad string: %s", s)
+	}
+}
+
+func TestLRU_ExpiringSoon(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Hour)
+	l.Put(2, 2, 0)
+	l.Put(3, 3, time.Minute)
+	l.Put(4, 4, time.Second)
+	l.Put(5, 5, 0)
+	l.Put(6, 6, time.Minute)
+	l.PutMiss(7, time.Millisecond)
+	clock.Advance(2 * time.Second)
+
+	entries := l.ExpiringSoon()
+	want := []int{3, 6, 1, 2, 5}
+	if len(entries) != len(want) {
+		t.Fatalf("bad entries: %+v", entries)
+	}
+	for i, e := range entries {
+		if e.Key != want[i] {
+			t.Fatalf("bad order: %+v", entries)
+		}
+	}
+	if entries[0].TTL != time.Minute-2*time.Second {
+		t.Fatalf("bad ttl: %v", entries[0].TTL)
+	}
+}
+
+func TestLRU_NextExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.NextExpiry(); ok {
+		t.Fatalf("an empty cache should not expire")
+	}
+	l.Put(1, 1, 0)
+	if _, ok := l.NextExpiry(); ok {
+		t.Fatalf("1 should never expire")
+	}
+	l.Put(2, 2, time.Hour)
+	l.Put(3, 3, time.Minute)
+	l.PutMiss(4, 2*time.Minute)
+	if next, ok := l.NextExpiry(); !ok || !next.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("bad next expiry: %v %v", next, ok)
+	}
+	l.Remove(3)
+	if next, ok := l.NextExpiry(); !ok || !next.Equal(clock.Now().Add(2*time.Minute)) {
+		t.Fatalf("bad next expiry: %v %v", next, ok)
+	}
+
+	clock.Advance(time.Hour + time.Second)
+	if next, ok := l.NextExpiry(); !ok || !next.Before(clock.Now()) {
+		t.Fatalf("the expired entries should be due: %v %v", next, ok)
+	}
+	l.FlushExpired()
+	if _, ok := l.NextExpiry(); ok {
+		t.Fatalf("nothing should expire")
+	}
+	l.Invalidate()
+	if next, ok := l.NextExpiry(); !ok || !next.Equal(clock.Now()) {
+		t.Fatalf("the stale entries should be due: %v %v", next, ok)
+	}
+}
@@ -0,0 +1,47 @@
+package lrucache
+
+// eventsBuffer is the capacity of the channels returned by Events
+const eventsBuffer = 64
+
+// EvictEvent describes an entry leaving the cache
+type EvictEvent struct {
+	Key    interface{}
+	Value  interface{}
+	Reason EvictReason
+}
+
+// Events subscribes to the evictions, returning a channel receiving an event for each
+// entry leaving the cache for any reason, like the callback of NewLRUCacheWithEvictReason.
+// The events are sent without blocking the cache: the channel is buffered and an event
+// is dropped when the buffer is full. Call CloseEvents to unsubscribe.
+func (c *LruCache) Events() <-chan EvictEvent {
+	c.writeLock()
+	defer c.writeUnlock()
+	events := make(chan EvictEvent, eventsBuffer)
+	c.events = append(c.events, events)
+	return events
+}
+
+// CloseEvents unsubscribes and closes a channel returned by Events.
+// It does nothing if the channel is already closed.
+func (c *LruCache) CloseEvents(events <-chan EvictEvent) {
+	c.writeLock()
+	defer c.writeUnlock()
+	for i, ch := range c.events {
+		if ch == events {
+			close(ch)
+			c.events = append(c.events[:i], c.events[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish sends ev to the subscribers whose buffer is not full, the write lock must be held
+func (c *LruCache) publish(ev EvictEvent) {
+	for _, ch := range c.events {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
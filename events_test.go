@@ -0,0 +1,65 @@
+package lrucache
+
+import (
+	"testing"
+)
+
+func TestLRU_Events(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	events := l.Events()
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, Expired)
+	l.Put(2, 20, Expired)
+	l.Remove(3)
+	l.PutMiss(4, Expired)
+	l.Clear()
+
+	want := []EvictEvent{
+		{Key: 1, Value: 1, Reason: ReasonCapacity},
+		{Key: 2, Value: 2, Reason: ReasonReplaced},
+		{Key: 3, Value: 3, Reason: ReasonRemoved},
+		{Key: 2, Value: 20, Reason: ReasonCleared},
+	}
+	for _, w := range want {
+		if ev := <-events; ev != w {
+			t.Fatalf("bad event: %+v, want %+v", ev, w)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event: %+v", ev)
+	default:
+	}
+
+	l.CloseEvents(events)
+	l.CloseEvents(events)
+	if _, ok := <-events; ok {
+		t.Fatalf("events should be closed")
+	}
+	l.Put(5, 5, Expired)
+	l.Remove(5)
+}
+
+func TestLRU_EventsDropped(t *testing.T) {
+	l, err := NewLRUCache(1, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	events := l.Events()
+	defer l.CloseEvents(events)
+
+	for i := 0; i < eventsBuffer*2; i++ {
+		l.Put(i, i, Expired)
+	}
+	if len(events) != eventsBuffer {
+		t.Fatalf("bad buffered events: %v", len(events))
+	}
+	if ev := <-events; ev.Key != 0 {
+		t.Fatalf("the oldest events should be kept: %+v", ev)
+	}
+}
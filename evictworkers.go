@@ -0,0 +1,82 @@
+package lrucache
+
+import (
+	"sync"
+)
+
+// evictQueueSize is the number of evictions queued per worker before the writers fire them
+const evictQueueSize = 64
+
+// evictWorkers fires the eviction callbacks in a pool of goroutines
+type evictWorkers struct {
+	queue   chan eviction
+	workers sync.WaitGroup
+	// lock guards closed against the dispatches racing with close
+	lock   sync.RWMutex
+	closed bool
+}
+
+// WithEvictWorkers makes n goroutines fire the eviction callbacks, so slow callbacks like
+// closing connections don't hold up the cache operations. The write lock is released
+// before the evictions are queued, and once n*64 of them are queued the writers fire the
+// next ones themselves, so a callback writing to the cache can't wait on the workers.
+// The callbacks run concurrently and in no particular order, they must be safe for
+// concurrent use. Clear waits for the callbacks of the entries it removed and
+// Close for all the queued ones before stopping the workers, the callbacks fire in the
+// calling goroutine afterwards.
+// Clear and Close must not be called from a callback. n <= 0 fires the callbacks under the lock.
+func WithEvictWorkers(n int) Option {
+	return func(c *LruCache) {
+		if n <= 0 {
+			return
+		}
+		w := &evictWorkers{queue: make(chan eviction, n*evictQueueSize)}
+		w.workers.Add(n)
+		for i := 0; i < n; i++ {
+			go w.run()
+		}
+		c.evictWorkers = w
+	}
+}
+
+// run fires the queued evictions until the queue is closed
+func (w *evictWorkers) run() {
+	defer w.workers.Done()
+	for ev := range w.queue {
+		ev.fire()
+		if ev.done != nil {
+			ev.done.Done()
+		}
+	}
+}
+
+// dispatch queues ev, or fires it in the calling goroutine if the queue is full or the workers
+// are stopped. Waiting for a slot would deadlock once every worker is in a callback writing
+// to the cache.
+func (w *evictWorkers) dispatch(ev eviction) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if !w.closed {
+		select {
+		case w.queue <- ev:
+			return
+		default:
+		}
+	}
+	ev.fire()
+	if ev.done != nil {
+		ev.done.Done()
+	}
+}
+
+// close waits for the queued evictions and stops the workers, it is safe to call it more than once
+func (w *evictWorkers) close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.workers.Wait()
+}
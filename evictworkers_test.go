@@ -0,0 +1,103 @@
+package lrucache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_EvictWorkers(t *testing.T) {
+	var evicted int32
+	release := make(chan struct{})
+	onEvicted := func(k interface{}, v interface{}) {
+		<-release
+		atomic.AddInt32(&evicted, 1)
+	}
+	l, err := NewLRUCache(2, Expired, onEvicted, WithEvictWorkers(2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	// the slow callbacks don't hold up the writers
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	if v, ok := l.Get(3); !ok || v != 3 || atomic.LoadInt32(&evicted) != 0 {
+		t.Fatalf("the cache should not wait for the callbacks")
+	}
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&evicted) == 2 })
+
+	// Clear returns once the callbacks of its entries returned
+	l.Clear()
+	if n := atomic.LoadInt32(&evicted); n != 4 {
+		t.Fatalf("bad evict count: %v", n)
+	}
+}
+
+func TestLRU_EvictWorkersClose(t *testing.T) {
+	var lock sync.Mutex
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		evicted = append(evicted, k)
+		lock.Unlock()
+	}
+	l, err := NewLRUCache(1, Expired, onEvicted, WithEvictWorkers(1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// more evictions than the queue holds are fired by the writers
+	for i := 0; i <= 2*evictQueueSize; i++ {
+		l.Put(i, i, Expired)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lock.Lock()
+	if len(evicted) != 2*evictQueueSize {
+		t.Fatalf("Close should wait for the queued callbacks: %v", len(evicted))
+	}
+	lock.Unlock()
+
+	// the callbacks fire in the calling goroutine after Close
+	l.Remove(2 * evictQueueSize)
+	if len(evicted) != 2*evictQueueSize+1 {
+		t.Fatalf("bad evict count: %v", len(evicted))
+	}
+	l.Close()
+}
+
+func TestLRU_EvictWorkersReentrant(t *testing.T) {
+	var l *LruCache
+	var evicted int32
+	onEvicted := func(k interface{}, v interface{}) {
+		// a callback writing to the cache evicts in turn while the queue is full
+		if n := atomic.AddInt32(&evicted, 1); n < 4*evictQueueSize {
+			l.Put(-int(n), v, Expired)
+		}
+	}
+	l, err := NewLRUCache(4, Expired, onEvicted, WithEvictWorkers(1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*evictQueueSize; i++ {
+			l.Put(i, i, Expired)
+		}
+		l.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("a callback writing to the cache should not deadlock")
+	}
+	if l.Len() != 4 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
@@ -0,0 +1,167 @@
+package lrucache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Cache implements a thread safe fixed size Expire LRU cache with typed keys and values
+type Cache[K comparable, V any] struct {
+	size      int
+	evictList *list.List
+	cache     map[K]*list.Element
+	ttl       time.Duration
+	onEvict   func(key K, value V)
+	lock      sync.RWMutex
+}
+
+// typedEntry is used to hold a value in the evictList of a Cache
+type typedEntry[K comparable, V any] struct {
+	key   K
+	value V
+	//if tll is nil, entry is not expire auto
+	ttl *time.Time
+}
+
+func (e *typedEntry[K, V]) IsExpired() bool {
+	if e.ttl == nil {
+		return false
+	}
+	return time.Now().After(*e.ttl)
+}
+
+// NewCache creates a typed expiring cache with the given size
+func NewCache[K comparable, V any](maxSize int, ttl time.Duration, onEvict func(key K, value V)) (*Cache[K, V], error) {
+	if maxSize <= 0 {
+		return nil, errors.New("Must provide a positive size to cache")
+	}
+	c := &Cache[K, V]{
+		size:      maxSize,
+		evictList: list.New(),
+		cache:     make(map[K]*list.Element),
+		ttl:       ttl,
+		onEvict:   onEvict,
+	}
+	return c, nil
+}
+
+// Get a key's value from the cache, the zero value of V is returned on a miss.
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.cache[key]; ok {
+		if ent.Value.(*typedEntry[K, V]).IsExpired() {
+			c.removeElement(ent)
+			return value, false
+		}
+		c.evictList.MoveToFront(ent)
+		return ent.Value.(*typedEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+// removeElement is used to remove a given list element from the cache
+func (c *Cache[K, V]) removeElement(e *list.Element) {
+	c.evictList.Remove(e)
+	kv := e.Value.(*typedEntry[K, V])
+	delete(c.cache, kv.key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// Put adds the value to the cache at key with the specified maximum duration.
+func (c *Cache[K, V]) Put(key K, value V, ttl time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var ex *time.Time = nil
+	if ttl > 0 {
+		expire := time.Now().Add(ttl)
+		ex = &expire
+	} else if c.ttl > 0 {
+		expire := time.Now().Add(c.ttl)
+		ex = &expire
+	}
+	//Check for existing item
+	if ent, ok := c.cache[key]; ok {
+		c.evictList.MoveToFront(ent)
+		ent.Value.(*typedEntry[K, V]).value = value
+		ent.Value.(*typedEntry[K, V]).ttl = ex
+		return false
+	}
+	// Add new item
+	ent := &typedEntry[K, V]{
+		key:   key,
+		value: value,
+		ttl:   ex,
+	}
+	c.cache[key] = c.evictList.PushFront(ent)
+	evict := c.evictList.Len() > c.size
+	// Verify size not exceeded
+	if evict {
+		c.removeOldest()
+	}
+	return evict
+}
+
+// removeOldest removes the oldest item from the cache
+func (c *Cache[K, V]) removeOldest() {
+	ent := c.evictList.Back()
+	if ent != nil {
+		c.removeElement(ent)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *Cache[K, V]) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.evictList.Len()
+}
+
+// Remove removes the provided key from the cache.
+func (c *Cache[K, V]) Remove(key K) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.cache[key]; ok {
+		c.removeElement(ent)
+		return true
+	}
+	return false
+}
+
+// Contains Check if a key exsists in cache without updating the recent-ness.
+func (c *Cache[K, V]) Contains(key K) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if ent, ok := c.cache[key]; ok {
+		return !ent.Value.(*typedEntry[K, V]).IsExpired()
+	}
+	return false
+}
+
+// Keys return all the keys in cache, from oldest to newest
+func (c *Cache[K, V]) Keys() []K {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	keys := make([]K, 0, len(c.cache))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		keys = append(keys, ent.Value.(*typedEntry[K, V]).key)
+	}
+	return keys
+}
+
+// Clear remove all the keys in cache
+func (c *Cache[K, V]) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for k, v := range c.cache {
+		if c.onEvict != nil {
+			c.onEvict(k, v.Value.(*typedEntry[K, V]).value)
+		}
+		delete(c.cache, k)
+	}
+	c.evictList.Init()
+}
@@ -0,0 +1,55 @@
+package lrucache
+
+import (
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k int, v string) {
+		evictCounter += 1
+	}
+	l, err := NewCache[int, string](16, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		l.Put(i, string(rune('a'+i)), Expired)
+	}
+	if l.Len() != 16 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if evictCounter != 16 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+
+	for i, k := range l.Keys() {
+		if v, ok := l.Get(k); !ok || k != i+16 || v != string(rune('a'+k)) {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if v, ok := l.Get(0); ok || v != "" {
+		t.Fatalf("should be evicted and return the zero value: %q", v)
+	}
+	if !l.Remove(16) || l.Remove(16) {
+		t.Fatalf("16 should be removed once")
+	}
+	if !l.Contains(17) || l.Contains(16) {
+		t.Fatalf("bad contains")
+	}
+
+	l.Clear()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, ok := l.Get(30); ok {
+		t.Fatalf("should contain nothing")
+	}
+}
+
+func TestCache_Invalid(t *testing.T) {
+	if _, err := NewCache[string, int](0, Expired, nil); err == nil {
+		t.Fatalf("should reject a zero size")
+	}
+}
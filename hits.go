@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// KeyCount is a key along with its number of Get hits
+type KeyCount struct {
+	Key  interface{}
+	Hits uint64
+}
+
+// WithHitCounts sets whether the cache counts the Get hits of each key for HitCount and TopKeys.
+// The count is on by default and costs an atomic add per hit, and 8 bytes per entry.
+func WithHitCounts(enabled bool) Option {
+	return func(c *LruCache) {
+		c.noHitCounts = !enabled
+	}
+}
+
+// WithAccessTimes sets whether the cache records when each key was last hit by a Get,
+// for LastAccess. The record is off by default since it costs a clock read per hit,
+// which WithoutExpiry otherwise saves.
+func WithAccessTimes(enabled bool) Option {
+	return func(c *LruCache) {
+		c.accessTimes = enabled
+	}
+}
+
+// countHit counts a Get hit on e and records its access time, the lock must be held
+func (c *LruCache) countHit(e *entry) {
+	if !c.noHitCounts {
+		atomic.AddUint64(&e.hits, 1)
+	}
+	if c.accessTimes {
+		atomic.StoreInt64(&e.accessed, c.now().UnixNano())
+	}
+}
+
+// LastAccess returns when a live key was last hit by a Get, or stored if it wasn't hit since,
+// without updating the recent-ness. Without WithAccessTimes it is when the key was stored.
+// ok is false if the key is missing, expired or negative.
+func (c *LruCache) LastAccess(key interface{}) (accessed time.Time, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) || kv.miss {
+		return time.Time{}, false
+	}
+	return time.Unix(0, atomic.LoadInt64(&kv.accessed)), true
+}
+
+// HitCount returns the number of Get hits of a live key since it was added,
+// the updates of its value keep the count. ok is false if the key is missing or expired.
+func (c *LruCache) HitCount(key interface{}) (count uint64, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return 0, false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) || kv.miss {
+		return 0, false
+	}
+	return atomic.LoadUint64(&kv.hits), true
+}
+
+// TopKeys returns up to n live keys with the most Get hits, from the most hit one,
+// and the most recently used first among equals. It sorts all the entries, in O(n log n).
+func (c *LruCache) TopKeys(n int) []KeyCount {
+	c.readLock()
+	counts := make([]KeyCount, 0, len(c.cache))
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		counts = append(counts, KeyCount{Key: kv.key, Hits: atomic.LoadUint64(&kv.hits)})
+	}
+	c.lock.RUnlock()
+	sort.SliceStable(counts, func(i, j int) bool {
+		return counts[i].Hits > counts[j].Hits
+	})
+	if n < 0 {
+		n = 0
+	}
+	if n < len(counts) {
+		counts = counts[:n]
+	}
+	return counts
+}
@@ -0,0 +1,140 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_HitCount(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	for i := 0; i < 3; i++ {
+		l.Get(2)
+	}
+	l.Get(1)
+	l.MGet([]interface{}{1, 3})
+	l.Peek(0)
+	l.Put(2, 20, Expired)
+
+	if n, ok := l.HitCount(2); !ok || n != 3 {
+		t.Fatalf("bad hit count: %v", n)
+	}
+	if n, ok := l.HitCount(0); !ok || n != 0 {
+		t.Fatalf("bad hit count: %v", n)
+	}
+	if _, ok := l.HitCount(5); ok {
+		t.Fatalf("5 should be missing")
+	}
+
+	top := l.TopKeys(3)
+	want := []KeyCount{{2, 3}, {1, 2}, {3, 1}}
+	if len(top) != 3 || top[0] != want[0] || top[1] != want[1] || top[2] != want[2] {
+		t.Fatalf("bad top keys: %v", top)
+	}
+	if top := l.TopKeys(100); len(top) != 4 {
+		t.Fatalf("bad top keys: %v", top)
+	}
+
+	l.Put(1, 1, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if _, ok := l.HitCount(1); ok {
+		t.Fatalf("1 should be expired")
+	}
+}
+
+func TestLRU_WithoutHitCounts(t *testing.T) {
+	l, err := NewLRUCache(8, Expired, nil, WithHitCounts(false))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Get(1)
+	if n, ok := l.HitCount(1); !ok || n != 0 {
+		t.Fatalf("hits should not be counted: %v", n)
+	}
+	s := l.Snapshot()
+	s.Get(1)
+	if n, ok := s.HitCount(1); !ok || n != 0 {
+		t.Fatalf("the snapshot should not count hits either: %v", n)
+	}
+}
+
+func TestLRU_HitCountSnapshot(t *testing.T) {
+	l, err := NewLRUCache(8, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			l.Get(1)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		l.Snapshot()
+	}
+	<-done
+	if n, _ := l.Snapshot().HitCount(1); n != 1000 {
+		t.Fatalf("snapshot should copy the hit count: %v", n)
+	}
+}
+
+func TestLRU_LastAccess(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	l, err := NewLRUCache(8, Expired, nil, WithClock(clock), WithAccessTimes(true))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	if at, ok := l.LastAccess(1); !ok || !at.Equal(start) {
+		t.Fatalf("a stored key should report its store time: %v", at)
+	}
+
+	clock.Advance(time.Second)
+	l.Get(1)
+	l.Peek(2)
+	l.Get(3)
+	if at, ok := l.LastAccess(1); !ok || !at.Equal(start.Add(time.Second)) {
+		t.Fatalf("bad last access: %v", at)
+	}
+	if at, ok := l.LastAccess(2); !ok || !at.Equal(start) {
+		t.Fatalf("Peek should not count as an access: %v", at)
+	}
+
+	clock.Advance(time.Second)
+	l.Put(1, 10, Expired)
+	if at, _ := l.LastAccess(1); !at.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("Put should reset the last access: %v", at)
+	}
+	if _, ok := l.LastAccess(3); ok {
+		t.Fatalf("3 should be missing")
+	}
+	clock.Advance(Expired)
+	if _, ok := l.LastAccess(2); ok {
+		t.Fatalf("2 should be expired")
+	}
+}
+
+func TestLRU_WithoutAccessTimes(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	l, err := NewLRUCache(8, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	clock.Advance(time.Second)
+	l.Get(1)
+	if at, ok := l.LastAccess(1); !ok || !at.Equal(time.Unix(1000, 0)) {
+		t.Fatalf("gets should not be recorded: %v", at)
+	}
+}
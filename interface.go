@@ -0,0 +1,73 @@
+package lrucache
+
+import (
+	"time"
+)
+
+// Interface is the method set shared by the untyped caches of the package, LruCache,
+// ShardedLruCache, LfuCache, TwoQueueCache and TieredCache, so code can depend on it and
+// be given a fake or a NullCache in tests. It is kept to these methods, the other ones stay specific
+// to each cache, so adding a method to the caches doesn't break the implementations
+// outside of the package.
+type Interface interface {
+	// Get returns the value of a live key, updating its recent-ness
+	Get(key interface{}) (value interface{}, ok bool)
+	// Put adds the value at key for ttl, it returns true if an entry was evicted
+	Put(key interface{}, value interface{}, ttl time.Duration) bool
+	// Remove removes key, it returns true if it was cached
+	Remove(key interface{}) bool
+	// Contains returns true if key is cached, without updating its recent-ness
+	Contains(key interface{}) bool
+	// Len returns the number of entries
+	Len() int
+	// Keys returns the cached keys
+	Keys() []interface{}
+	// Clear removes all the keys
+	Clear()
+}
+
+var (
+	_ Interface = (*LruCache)(nil)
+	_ Interface = (*ShardedLruCache)(nil)
+	_ Interface = (*LfuCache)(nil)
+	_ Interface = (*TwoQueueCache)(nil)
+	_ Interface = (*TieredCache)(nil)
+	_ Interface = NullCache{}
+)
+
+// NullCache is an Interface which caches nothing, for disabling caching:
+// Put drops the values and Get always misses.
+type NullCache struct{}
+
+// Get always misses.
+func (NullCache) Get(key interface{}) (value interface{}, ok bool) {
+	return nil, false
+}
+
+// Put drops the value.
+func (NullCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
+	return false
+}
+
+// Remove always returns false.
+func (NullCache) Remove(key interface{}) bool {
+	return false
+}
+
+// Contains always returns false.
+func (NullCache) Contains(key interface{}) bool {
+	return false
+}
+
+// Len always returns 0.
+func (NullCache) Len() int {
+	return 0
+}
+
+// Keys always returns nil.
+func (NullCache) Keys() []interface{} {
+	return nil
+}
+
+// Clear does nothing.
+func (NullCache) Clear() {}
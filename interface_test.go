@@ -0,0 +1,30 @@
+package lrucache
+
+import (
+	"testing"
+)
+
+func TestInterface(t *testing.T) {
+	l, _ := NewLRUCache(2, Expired, nil)
+	s, _ := NewShardedLRUCache(2, 4, Expired, nil)
+	for _, c := range []Interface{l, s, NullCache{}} {
+		c.Put(1, 1, Expired)
+		if v, ok := c.Get(1); ok != c.Contains(1) || (ok && v != 1) {
+			t.Fatalf("%T: bad value: %v %v", c, v, ok)
+		}
+		c.Clear()
+		if c.Len() != 0 || len(c.Keys()) != 0 || c.Remove(1) {
+			t.Fatalf("%T should be empty", c)
+		}
+	}
+}
+
+func TestNullCache(t *testing.T) {
+	var c Interface = NullCache{}
+	if c.Put(1, 1, Expired) || c.Contains(1) || c.Len() != 0 || c.Keys() != nil {
+		t.Fatalf("null cache should cache nothing")
+	}
+	if _, ok := c.Get(1); ok {
+		t.Fatalf("null cache should miss")
+	}
+}
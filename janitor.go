@@ -0,0 +1,91 @@
+package lrucache
+
+import (
+	"container/list"
+	"time"
+)
+
+// StartJanitor starts a goroutine removing expired entries every interval,
+// firing onEvict for each of them. A running janitor is replaced.
+func (c *LruCache) StartJanitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	c.janitorLock.Lock()
+	defer c.janitorLock.Unlock()
+	c.stopJanitor()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	c.janitorStop = stop
+	c.janitorDone = done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.removeExpiredEntries()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopJanitor stops the janitor goroutine and waits for it to exit.
+// It is safe to call it more than once or without a running janitor.
+func (c *LruCache) StopJanitor() {
+	c.janitorLock.Lock()
+	defer c.janitorLock.Unlock()
+	c.stopJanitor()
+}
+
+// stopJanitor must be called with janitorLock held
+func (c *LruCache) stopJanitor() {
+	if c.janitorStop == nil {
+		return
+	}
+	close(c.janitorStop)
+	<-c.janitorDone
+	c.janitorStop = nil
+	c.janitorDone = nil
+}
+
+// FlushExpired removes all the expired entries right away, firing onEvict for each of them,
+// and returns how many were removed. It does the work of the janitor without a goroutine.
+func (c *LruCache) FlushExpired() int {
+	return c.removeExpiredEntries()
+}
+
+// Compact removes all the expired entries like FlushExpired, then rebuilds the map of the
+// cache to its current length, as a Go map never gives back the memory of its deleted keys.
+// It returns how many entries were removed. Rebuilding copies every live key under the write
+// lock, so it is meant for idle periods after a burst of expirations or removals.
+func (c *LruCache) Compact() int {
+	removed := c.removeExpiredEntries()
+	c.writeLock()
+	defer c.writeUnlock()
+	cache := make(map[interface{}]*list.Element, len(c.cache))
+	for k, v := range c.cache {
+		cache[k] = v
+	}
+	c.cache = cache
+	return removed
+}
+
+// removeExpiredEntries removes all the expired entries and returns how many were removed
+func (c *LruCache) removeExpiredEntries() int {
+	c.writeLock()
+	defer c.writeUnlock()
+	removed := 0
+	for ent := c.evictList.Back(); ent != nil; {
+		prev := ent.Prev()
+		if c.expired(ent.Value.(*entry)) {
+			c.removeElement(ent, ReasonExpired)
+			removed++
+		}
+		ent = prev
+	}
+	return removed
+}
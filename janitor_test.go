@@ -0,0 +1,98 @@
+package lrucache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_Janitor(t *testing.T) {
+	var evictCounter int32
+	onEvicted := func(k interface{}, v interface{}) {
+		atomic.AddInt32(&evictCounter, 1)
+	}
+	l, err := NewLRUCache(16, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 8; i++ {
+		l.Put(i, i, 10*time.Millisecond)
+	}
+	l.Put(8, 8, Expired)
+
+	l.StartJanitor(5 * time.Millisecond)
+	defer l.StopJanitor()
+	deadline := time.Now().Add(time.Second)
+	for l.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if l.Len() != 1 {
+		t.Fatalf("expired entries should be removed: %v", l.Len())
+	}
+	if n := atomic.LoadInt32(&evictCounter); n != 8 {
+		t.Fatalf("bad evict count: %v", n)
+	}
+
+	l.StopJanitor()
+	l.StopJanitor()
+}
+
+func TestLRU_FlushExpired(t *testing.T) {
+	var reasons []EvictReason
+	onEvicted := func(k interface{}, v interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCacheWithEvictReason(8, Expired, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		ttl := Expired
+		if i%2 == 0 {
+			ttl = 10 * time.Millisecond
+		}
+		l.Put(i, i, ttl)
+	}
+	clock.Advance(20 * time.Millisecond)
+
+	if n := l.FlushExpired(); n != 3 || l.Len() != 3 {
+		t.Fatalf("bad flushed count: %v", n)
+	}
+	for _, r := range reasons {
+		if r != ReasonExpired {
+			t.Fatalf("bad reasons: %v", reasons)
+		}
+	}
+	if n := l.FlushExpired(); n != 0 {
+		t.Fatalf("bad flushed count: %v", n)
+	}
+}
+
+func TestLRU_Compact(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(1000, time.Second, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		l.Put(i, i, 0)
+	}
+	l.Put(0, 0, time.Hour)
+	l.Put(1, 1, time.Hour)
+	clock.Advance(2 * time.Second)
+
+	if n := l.Compact(); n != 998 || l.Len() != 2 {
+		t.Fatalf("bad compacted count: %v", n)
+	}
+	if keys := l.Keys(); len(keys) != 2 || keys[0] != 0 || keys[1] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if n := l.Compact(); n != 0 || l.Len() != 2 {
+		t.Fatalf("bad compacted count: %v", n)
+	}
+}
@@ -0,0 +1,217 @@
+package lrucache
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LfuCache implements a thread safe fixed size Expire LFU cache,
+// evicting the least frequently used entry and the least recent one among equals
+type LfuCache struct {
+	size    int
+	items   map[interface{}]*lfuEntry
+	heap    lfuHeap
+	ttl     time.Duration
+	onEvict EvictCallback
+	// tick orders the accesses to break frequency ties
+	tick uint64
+	lock sync.Mutex
+}
+
+// lfuEntry is used to hold a value in the LfuCache heap
+type lfuEntry struct {
+	entry
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+// evictedBefore reports whether a is evicted before b
+func evictedBefore(a, b *lfuEntry) bool {
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+// lfuHeap is a min heap of entries ordered by frequency then last access
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool { return evictedBefore(h[i], h[j]) }
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// NewLFUCache creates an expiring LFU cache with the given size
+func NewLFUCache(maxSize int, ttl time.Duration, onEvict EvictCallback) (*LfuCache, error) {
+	if maxSize <= 0 {
+		return nil, errNonPositiveSize
+	}
+	c := &LfuCache{
+		size:    maxSize,
+		items:   make(map[interface{}]*lfuEntry),
+		ttl:     ttl,
+		onEvict: onEvict,
+	}
+	return c, nil
+}
+
+// touch counts an access to e
+func (c *LfuCache) touch(e *lfuEntry) {
+	c.tick++
+	e.freq++
+	e.tick = c.tick
+	heap.Fix(&c.heap, e.index)
+}
+
+// removeEntry is used to remove a given entry from the cache
+func (c *LfuCache) removeEntry(e *lfuEntry) {
+	heap.Remove(&c.heap, e.index)
+	delete(c.items, e.key)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+// Get a key's value from the cache, counting an access.
+func (c *LfuCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		if e.IsExpired() {
+			c.removeEntry(e)
+			return nil, false
+		}
+		c.touch(e)
+		return e.value, true
+	}
+	return nil, false
+}
+
+// Peek returns a key's value without counting an access.
+func (c *LfuCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		if e.IsExpired() {
+			c.removeEntry(e)
+			return nil, false
+		}
+		return e.value, true
+	}
+	return nil, false
+}
+
+// Put adds the value to the cache at key with the specified maximum duration,
+// an update counts as an access. It returns true if an eviction occurred.
+func (c *LfuCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	var ex *time.Time
+	if ttl > 0 {
+		expire := time.Now().Add(ttl)
+		ex = &expire
+	}
+	//Check for existing item
+	if e, ok := c.items[key]; ok {
+		e.value = value
+		e.ttl = ex
+		c.touch(e)
+		return false
+	}
+	evict := len(c.items) >= c.size
+	if evict {
+		c.removeEntry(c.heap[0])
+	}
+	c.tick++
+	e := &lfuEntry{
+		entry: entry{key: key, value: value, ttl: ex},
+		freq:  1,
+		tick:  c.tick,
+	}
+	heap.Push(&c.heap, e)
+	c.items[key] = e
+	return evict
+}
+
+// Len returns the number of items in the cache.
+func (c *LfuCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.items)
+}
+
+// Remove removes the provided key from the cache.
+func (c *LfuCache) Remove(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e)
+		return true
+	}
+	return false
+}
+
+// Contains Check if a key exsists in cache without counting an access.
+func (c *LfuCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		return !e.IsExpired()
+	}
+	return false
+}
+
+// Keys return all the keys in cache in eviction order, from the least to the most frequently used
+func (c *LfuCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entries := make([]*lfuEntry, len(c.heap))
+	copy(entries, c.heap)
+	sort.Slice(entries, func(i, j int) bool {
+		return evictedBefore(entries[i], entries[j])
+	})
+	keys := make([]interface{}, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// Clear remove all the keys in cache
+func (c *LfuCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for k, e := range c.items {
+		if c.onEvict != nil {
+			c.onEvict(k, e.value)
+		}
+		delete(c.items, k)
+	}
+	c.heap = nil
+}
@@ -0,0 +1,92 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLFUCache(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLFUCache(16, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		l.Put(i, i, Expired)
+	}
+	if l.Len() != 16 || evictCounter != 16 {
+		t.Fatalf("bad len: %v, evict count: %v", l.Len(), evictCounter)
+	}
+	for i, k := range l.Keys() {
+		if v, ok := l.Peek(k); !ok || v != k || v != i+16 {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if !l.Remove(16) || l.Remove(16) || l.Contains(16) {
+		t.Fatalf("16 should be removed")
+	}
+	l.Put(40, 40, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := l.Get(40); ok {
+		t.Fatalf("40 should be expired")
+	}
+	l.Clear()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := NewLFUCache(0, Expired, nil); err == nil {
+		t.Fatalf("should reject a zero size")
+	}
+}
+
+// Test that the same access pattern evicts a different entry under LFU and LRU
+func TestLFUCache_EvictsLeastFrequent(t *testing.T) {
+	lfu, err := NewLFUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lru, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, l := range []interface {
+		Put(key interface{}, value interface{}, ttl time.Duration) bool
+		Get(key interface{}) (interface{}, bool)
+	}{lfu, lru} {
+		l.Put("a", 1, Expired)
+		l.Put("b", 2, Expired)
+		l.Get("a")
+		l.Get("a")
+		l.Get("b")
+		l.Put("c", 3, Expired)
+	}
+
+	if !lfu.Contains("a") || lfu.Contains("b") {
+		t.Fatalf("LFU should evict the least frequently used b: %v", lfu.Keys())
+	}
+	if lru.Contains("a") || !lru.Contains("b") {
+		t.Fatalf("LRU should evict the least recently used a: %v", lru.Keys())
+	}
+}
+
+// Test that frequency ties are broken by recency
+func TestLFUCache_Ties(t *testing.T) {
+	l, err := NewLFUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put("a", 1, Expired)
+	l.Put("b", 2, Expired)
+	l.Get("b")
+	l.Get("a")
+	l.Put("c", 3, Expired)
+	if !l.Contains("a") || l.Contains("b") {
+		t.Fatalf("least recent b should be evicted: %v", l.Keys())
+	}
+}
@@ -0,0 +1,182 @@
+package lrucache
+
+import (
+	"context"
+	"time"
+)
+
+// Loader loads the value of a key missing from the cache along with the ttl to store it with,
+// a ttl of 0 uses the cache ttl like Put
+type Loader interface {
+	Load(key interface{}) (value interface{}, ttl time.Duration, err error)
+}
+
+// ContextLoader is a Loader which can be cancelled, GetWithContext passes it the context
+// of its caller instead of calling Load
+type ContextLoader interface {
+	Loader
+	LoadContext(ctx context.Context, key interface{}) (value interface{}, ttl time.Duration, err error)
+}
+
+// WithMaxConcurrentLoads limits the number of loads running at once to n, for GetOrLoad
+// and WithLoader alike, the callers of the other loads wait for a slot or until their
+// context is done. A n <= 0 doesn't limit the loads.
+func WithMaxConcurrentLoads(n int) Option {
+	return func(c *LruCache) {
+		c.loadSlots = nil
+		if n > 0 {
+			c.loadSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// LoaderFunc adapts a function to the Loader interface
+type LoaderFunc func(key interface{}) (value interface{}, ttl time.Duration, err error)
+
+// Load calls f(key)
+func (f LoaderFunc) Load(key interface{}) (interface{}, time.Duration, error) {
+	return f(key)
+}
+
+// WithLoader makes the cache read through: Get and Lookup load the missing keys with l
+// and store the result, sharing a single call between concurrent misses like GetOrLoad.
+// If the load fails nothing is stored.
+func WithLoader(l Loader) Option {
+	return func(c *LruCache) {
+		c.loader = l
+	}
+}
+
+// loadCall is an in-flight or completed loader call
+type loadCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// cancelled is set when the load failed because the context of its caller was done
+	cancelled bool
+}
+
+// GetOrLoad returns the key's value from the cache, or calls loader to compute it on a miss.
+// Concurrent misses on the same key share a single loader call. The loaded value is
+// stored with the cache ttl, if loader returns an error nothing is stored and the error
+// is returned to all the waiting callers. A key stored by PutMiss returns ErrCachedMiss
+// without calling loader.
+func (c *LruCache) GetOrLoad(key interface{}, loader func() (interface{}, error)) (interface{}, error) {
+	return c.GetOrLoadContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return loader()
+	})
+}
+
+// GetOrLoadContext is like GetOrLoad but stops waiting for an in-flight load when ctx is done,
+// returning ctx.Err(). The loader runs with the context of the caller which started the load,
+// if that load fails because its context is done the callers still waiting start a new one.
+func (c *LruCache) GetOrLoadContext(ctx context.Context, key interface{}, loader func(context.Context) (interface{}, error)) (interface{}, error) {
+	return c.getOrLoad(ctx, key, false, func(ctx context.Context) (interface{}, time.Duration, error) {
+		value, err := loader(ctx)
+		return value, 0, err
+	})
+}
+
+// loadThrough loads key with the Loader of the cache, passing it ctx if it is a ContextLoader.
+// It is called after a cache miss, which is not looked up nor counted again.
+func (c *LruCache) loadThrough(ctx context.Context, key interface{}) (interface{}, error) {
+	if l, ok := c.loader.(ContextLoader); ok {
+		return c.getOrLoad(ctx, key, true, func(ctx context.Context) (interface{}, time.Duration, error) {
+			return l.LoadContext(ctx, key)
+		})
+	}
+	return c.getOrLoad(ctx, key, true, func(context.Context) (interface{}, time.Duration, error) {
+		return c.loader.Load(key)
+	})
+}
+
+// GetWithContext gets a key's value like Get, for a caller bound to ctx like an HTTP handler.
+// If ctx is already done it returns right away without looking the key up. On a miss with a
+// Loader set by WithLoader, a ContextLoader loads with ctx so the load is aborted when ctx is
+// done, and a plain Loader runs to completion but GetWithContext stops waiting for the load
+// of another caller. The failed or cancelled loads store nothing.
+func (c *LruCache) GetWithContext(ctx context.Context, key interface{}) (value interface{}, ok bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	if value, ok = c.getCached(key); ok || c.loader == nil {
+		return value, ok
+	}
+	value, err := c.loadThrough(ctx, key)
+	return value, err == nil
+}
+
+// getOrLoad is GetOrLoadContext with a loader returning the ttl to store the value with.
+// missed skips the first cache lookup for a caller which just missed, so that the miss
+// is counted once in the stats and the admission sketch.
+func (c *LruCache) getOrLoad(ctx context.Context, key interface{}, missed bool, loader func(context.Context) (interface{}, time.Duration, error)) (interface{}, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !missed {
+			if value, ok := c.getCached(key); ok {
+				return value, nil
+			}
+		}
+		missed = false
+		if c.isMiss(key) {
+			return nil, ErrCachedMiss
+		}
+		c.loadLock.Lock()
+		if call, ok := c.loads[key]; ok {
+			c.loadLock.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if call.cancelled && ctx.Err() == nil {
+				continue
+			}
+			return call.value, call.err
+		}
+		// a load may have completed between Get and loadLock
+		if value, ok := c.Peek(key); ok {
+			c.loadLock.Unlock()
+			return value, nil
+		}
+		if c.loads == nil {
+			c.loads = make(map[interface{}]*loadCall)
+		}
+		call := &loadCall{done: make(chan struct{})}
+		c.loads[key] = call
+		c.loadLock.Unlock()
+
+		c.load(ctx, key, call, loader)
+		return call.value, call.err
+	}
+}
+
+// load runs loader for the in-flight call and wakes up the waiting callers
+func (c *LruCache) load(ctx context.Context, key interface{}, call *loadCall, loader func(context.Context) (interface{}, time.Duration, error)) {
+	defer func() {
+		c.loadLock.Lock()
+		delete(c.loads, key)
+		c.loadLock.Unlock()
+		close(call.done)
+	}()
+	if c.loadSlots != nil {
+		select {
+		case c.loadSlots <- struct{}{}:
+			defer func() { <-c.loadSlots }()
+		case <-ctx.Done():
+			call.err, call.cancelled = ctx.Err(), true
+			return
+		}
+	}
+	var ttl time.Duration
+	call.value, ttl, call.err = c.callLoader(ctx, loader)
+	if call.err == nil {
+		c.writeLock()
+		c.set(&entry{key: key, value: call.value, lifetime: c.lifetime(ttl), stored: true})
+		c.writeUnlock()
+	} else if ctx.Err() != nil {
+		call.cancelled = true
+	}
+}
@@ -0,0 +1,329 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRU_GetOrLoad(t *testing.T) {
+	l, err := NewLRUCache(16, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var calls int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.GetOrLoad("key", loader)
+			if err != nil || v != "value" {
+				t.Errorf("bad load: %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader should be called once: %v", n)
+	}
+	if v, ok := l.Get("key"); !ok || v != "value" {
+		t.Fatalf("loaded value should be cached: %v", v)
+	}
+}
+
+func TestLRU_GetOrLoadError(t *testing.T) {
+	l, err := NewLRUCache(16, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	loadErr := errors.New("load failed")
+	if _, err := l.GetOrLoad("key", func() (interface{}, error) {
+		return nil, loadErr
+	}); err != loadErr {
+		t.Fatalf("bad err: %v", err)
+	}
+	if l.Contains("key") {
+		t.Fatalf("failed load should not be cached")
+	}
+}
+
+func TestLRU_GetOrLoadContextCancel(t *testing.T) {
+	l, err := NewLRUCache(16, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go l.GetOrLoadContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "value", nil
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := l.GetOrLoadContext(ctx, "key", func(context.Context) (interface{}, error) {
+			t.Errorf("loader should not be called")
+			return nil, nil
+		})
+		done <- err
+	}()
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("bad err: %v", err)
+	}
+	close(release)
+}
+
+func TestLRU_GetOrLoadContextLeaderCancel(t *testing.T) {
+	l, err := NewLRUCache(16, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	leader := make(chan error)
+	go func() {
+		_, err := l.GetOrLoadContext(ctx, "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		leader <- err
+	}()
+	<-started
+
+	follower := make(chan interface{})
+	go func() {
+		v, err := l.GetOrLoadContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+			return "value", nil
+		})
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		follower <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Fatalf("bad err: %v", err)
+	}
+	if v := <-follower; v != "value" {
+		t.Fatalf("follower should load again: %v", v)
+	}
+}
+
+func TestLRU_WithLoader(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	loader := LoaderFunc(func(key interface{}) (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		if key == "bad" {
+			return nil, 0, errors.New("backend down")
+		}
+		return key.(int) * 10, 10 * time.Millisecond, nil
+	})
+	l, err := NewLRUCache(16, Expired, nil, WithLoader(loader))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := l.Get(1); !ok || v != 10 {
+				t.Errorf("bad value: %v", v)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("concurrent misses should share a load: %v", n)
+	}
+	if v, ok := l.Peek(1); !ok || v != 10 {
+		t.Fatalf("loaded value should be cached: %v", v)
+	}
+	if ttl, _ := l.TTL(1); ttl > 10*time.Millisecond {
+		t.Fatalf("the loader ttl should be used: %v", ttl)
+	}
+
+	if v, ok := l.Get("bad"); ok || v != nil {
+		t.Fatalf("failed load should miss: %v", v)
+	}
+	if _, err := l.Lookup("bad"); err == nil || err.Error() != "backend down" {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Contains("bad") {
+		t.Fatalf("nothing should be cached on error")
+	}
+
+	l.PutMiss(2, Expired)
+	if _, err := l.Lookup(2); err != ErrCachedMiss {
+		t.Fatalf("err: %v", err)
+	}
+	if _, ok := l.Get(2); ok || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("cached miss should not be loaded: %v", calls)
+	}
+}
+
+func TestLRU_WithLoaderStats(t *testing.T) {
+	loader := LoaderFunc(func(key interface{}) (interface{}, time.Duration, error) {
+		return key, 0, nil
+	})
+	l, err := NewLRUCache(16, Expired, nil, WithLoader(loader))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	// each read-through miss counts once
+	l.Get(1)
+	l.Lookup(2)
+	l.GetWithContext(context.Background(), 3)
+	if s := l.Stats(); s.Misses != 3 || s.Hits != 0 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	l.Get(1)
+	if s := l.Stats(); s.Misses != 3 || s.Hits != 1 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestLRU_MaxConcurrentLoads(t *testing.T) {
+	l, err := NewLRUCache(16, Expired, nil, WithMaxConcurrentLoads(2))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var running, peak int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := l.GetOrLoad(i, loader); err != nil {
+				t.Errorf("err: %v", err)
+			}
+		}(i)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&running) == 2 })
+
+	// a queued caller gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.GetOrLoadContext(ctx, 10, func(context.Context) (interface{}, error) {
+		t.Errorf("loader should not run")
+		return nil, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err: %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Fatalf("bad peak loads: %v", p)
+	}
+	if l.Len() != 6 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// contextLoader is a ContextLoader waiting for release or for its context
+type contextLoader struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (l *contextLoader) Load(key interface{}) (interface{}, time.Duration, error) {
+	return l.LoadContext(context.Background(), key)
+}
+
+func (l *contextLoader) LoadContext(ctx context.Context, key interface{}) (interface{}, time.Duration, error) {
+	atomic.AddInt32(&l.calls, 1)
+	select {
+	case <-l.release:
+		return key, 0, nil
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+func TestLRU_GetWithContext(t *testing.T) {
+	loader := &contextLoader{release: make(chan struct{})}
+	l, err := NewLRUCache(16, Expired, nil, WithLoader(loader))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+
+	// a done context returns without the lock nor a load
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.lock.Lock()
+	if _, ok := l.GetWithContext(cancelled, 1); ok {
+		t.Fatalf("a done context should miss")
+	}
+	if _, ok := l.GetWithContext(cancelled, 2); ok {
+		t.Fatalf("a done context should miss")
+	}
+	l.lock.Unlock()
+	if n := atomic.LoadInt32(&loader.calls); n != 0 {
+		t.Fatalf("nothing should be loaded: %v", n)
+	}
+
+	if v, ok := l.GetWithContext(context.Background(), 1); !ok || v != 1 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+
+	// the load is aborted when the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, ok := l.GetWithContext(ctx, 2); ok {
+		t.Fatalf("the load should be aborted")
+	}
+	if n := atomic.LoadInt32(&loader.calls); n != 1 || l.Contains(2) {
+		t.Fatalf("the aborted load should store nothing: %v", n)
+	}
+
+	close(loader.release)
+	if v, ok := l.GetWithContext(context.Background(), 2); !ok || v != 2 || !l.Contains(2) {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+}
@@ -2,43 +2,323 @@ package lrucache
 
 import (
 	"container/list"
+	"context"
 	"errors"
+	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// NoExpiration is the remaining lifetime reported for entries that never expire,
+// passed as ttl it stores an entry which never expires regardless of the cache ttl
+const NoExpiration time.Duration = -1
+
+// Unbounded passed as the size of NewLRUCache or Resize makes a cache bounded by the
+// expiry of its entries only, it never evicts an entry to make room
+const Unbounded = -1
+
+// maxPromotions is the number of Get hits buffered before they are applied
+const maxPromotions = 64
+
+// maxExpiredScan is the number of oldest entries looked at for an expired one
+// before evicting a live entry to make room
+const maxExpiredScan = 8
+
+var errNonPositiveSize = errors.New("Must provide a positive size to cache")
+
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback func(key interface{}, value interface{})
 
-// LruCache implements a thread safe fixed size Expire LRU cache
+// EvictReason tells why an entry left the cache
+type EvictReason int
+
+const (
+	// ReasonCapacity means the entry was evicted to make room for a new one
+	ReasonCapacity EvictReason = iota
+	// ReasonExpired means the entry ttl elapsed
+	ReasonExpired
+	// ReasonRemoved means the entry was removed explicitly
+	ReasonRemoved
+	// ReasonCleared means the entry was dropped by Clear
+	ReasonCleared
+	// ReasonReplaced means the entry value was overwritten by a Put
+	ReasonReplaced
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonExpired:
+		return "expired"
+	case ReasonRemoved:
+		return "removed"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// EvictReasonCallback is used to get a callback with the reason when a cache entry is evicted
+type EvictReasonCallback func(key interface{}, value interface{}, reason EvictReason)
+
+// LruCache implements a thread safe fixed size Expire LRU cache.
+// nil is a valid value: a stored nil is returned with ok true, only an absent,
+// expired or negative key is reported with ok false.
 type LruCache struct {
+	// stats counters are updated atomically, keep them first for 64-bit alignment
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+
 	size      int
 	evictList *list.List
 	cache     map[interface{}]*list.Element
 	ttl       time.Duration
 	onEvict   EvictCallback
 	lock      sync.RWMutex
+
+	// elements hit by Get under the read lock, waiting to be moved to the front
+	promotions  [maxPromotions]*list.Element
+	npromotions int32
+
+	onEvictReason EvictReasonCallback
+	onAdd         func(key, value interface{})
+	// onExpire replaces onEvict for the expired entries when set
+	onExpire EvictCallback
+	// subscribers of Events
+	events []chan EvictEvent
+	// asyncEvict defers the eviction callbacks until the write lock is released
+	asyncEvict bool
+	// evictWorkers fires the deferred eviction callbacks, set by WithEvictWorkers
+	evictWorkers *evictWorkers
+	deferred     []eviction
+
+	// onFull is called when an insert makes the fill reach fullRatio, full is set until it drops below
+	fullRatio float64
+	onFull    func(len, cap int)
+	full      bool
+
+	// sliding makes Get push the deadline of the entry forward
+	sliding bool
+	// fifo disables the recency updates, entries are evicted by insertion order
+	fifo bool
+	// policy tells which end of evictList is evicted
+	policy EvictionPolicy
+	// fullPolicy tells whether a new key evicts or is rejected when the cache is full
+	fullPolicy FullPolicy
+	// admission estimates the key frequencies for the admission policy, nil if it is disabled
+	admission *sketch
+
+	// total weight of the entries, bounded by size
+	weight int
+	// number of pinned entries
+	pinned int
+
+	// byte bound of a sized cache, zero if the cache is only bounded by count
+	maxBytes int64
+	bytes    int64
+	sizeOf   SizeFunc
+
+	loadLock sync.Mutex
+	loads    map[interface{}]*loadCall
+	// loadSlots is a semaphore bounding the running loads, nil if they are not bounded
+	loadSlots chan struct{}
+	// keys being reloaded by the refresh ahead, guarded by loadLock
+	refreshes     map[interface{}]struct{}
+	refreshWindow time.Duration
+	reload        func(key interface{}) (interface{}, error)
+	// loader makes Get and Lookup read through on a miss
+	loader Loader
+	// writeBehind queues the inserted values to the backing store
+	writeBehind *writeBehind
+	// autoTune resizes the cache toward a target hit ratio, set by WithAutoTune
+	autoTune *autoTuner
+
+	janitorLock sync.Mutex
+	janitorStop chan struct{}
+	janitorDone chan struct{}
+
+	// clock gives the time used for the expiry deadlines
+	clock Clock
+	// noHitCounts disables the per-key hit counts
+	noHitCounts bool
+	// accessTimes enables the per-key access times, set by WithAccessTimes
+	accessTimes bool
+	// noExpiry makes every entry permanent, set by WithoutExpiry
+	noExpiry bool
+	// evictBatch is the least number of entries evicted once the cache overflows
+	evictBatch int
+	// panicHandler receives the panics recovered from the callbacks, set by WithPanicHandler
+	panicHandler func(interface{})
+	// epoch is bumped by Invalidate, the entries stored in an earlier epoch count as expired
+	epoch uint64
+	// maxTTL caps the lifetime of every entry, zero if there is no cap
+	maxTTL time.Duration
+}
+
+// Option configures a LruCache at construction
+type Option func(*LruCache)
+
+// EvictionPolicy tells which entry is evicted to make room
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used entry
+	LRU EvictionPolicy = iota
+	// MRU evicts the most recently used entry other than the one being added,
+	// for access patterns like cyclic scans where the recent entries are the least likely reused
+	MRU
+)
+
+// WithEvictionPolicy sets which entry is evicted to make room, LRU by default.
+// The expired entries are still reclaimed first.
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(c *LruCache) {
+		c.policy = policy
+	}
+}
+
+// FullPolicy tells what a Put of a new key does when the cache is full
+type FullPolicy int
+
+const (
+	// PolicyEvict evicts entries to make room for the new key
+	PolicyEvict FullPolicy = iota
+	// PolicyReject drops the new key, leaving the cache unchanged
+	PolicyReject
+)
+
+// WithFullPolicy sets what a Put of a new key does when the cache is full, PolicyEvict by default.
+// With PolicyReject the new key is not cached, Put returns false and PutE ErrCacheFull,
+// while the existing keys can still be updated, even to a larger weight or size.
+// The expired entries are still reclaimed to make room.
+func WithFullPolicy(policy FullPolicy) Option {
+	return func(c *LruCache) {
+		c.fullPolicy = policy
+	}
+}
+
+// WithAsyncEvict sets whether the eviction callbacks run after the write lock is released
+// instead of while it is held, so they can call the cache, for example to Get or Put.
+// They still run in the goroutine which caused the evictions, in order, before it
+// returns from the cache. The default runs them under the lock, so they see the evictions
+// in the order of the cache operations across goroutines.
+func WithAsyncEvict(async bool) Option {
+	return func(c *LruCache) {
+		c.asyncEvict = async
+	}
+}
+
+// WithEvictBatch makes an insert overflowing the cache evict at least n entries, the oldest
+// ones, rather than just enough to fit, so the next n-1 inserts don't have to evict.
+// This trades a lower fill for less frequent evictions under a burst of inserts.
+// The entry being inserted is never evicted to complete a batch. n <= 1 evicts one at a time.
+func WithEvictBatch(n int) Option {
+	return func(c *LruCache) {
+		c.evictBatch = n
+	}
+}
+
+// WithPreallocation sizes the map of the cache for n entries from the start, usually the
+// cache size, to save its growth while the cache fills up.
+func WithPreallocation(n int) Option {
+	return func(c *LruCache) {
+		if n > 0 {
+			c.cache = make(map[interface{}]*list.Element, n)
+		}
+	}
+}
+
+// WithMaxTTL caps the lifetime of every entry to d, including the entries
+// stored with a longer ttl and the ones which would never expire. The cap counts from
+// when the entry was stored, Touch, ExtendTTL and the sliding expiration can't extend it.
+func WithMaxTTL(d time.Duration) Option {
+	return func(c *LruCache) {
+		c.maxTTL = d
+	}
+}
+
+// WithoutExpiry makes every entry permanent, ignoring the cache ttl, the ttl of each insert,
+// ExtendTTL and WithMaxTTL, for a pure LRU cache which skips the expiry checks on every access.
+// The entries which never expire already skip reading the clock on a hit, unless
+// WithAccessTimes is set, WithoutExpiry also saves looking for expired entries to evict first.
+func WithoutExpiry() Option {
+	return func(c *LruCache) {
+		c.noExpiry = true
+	}
 }
 
 // entry is used to hold a value in the evictList
 type entry struct {
-	key   interface{}
-	value interface{}
+	// hits counts the Get hits, updated atomically, keep it first for 64-bit alignment
+	hits uint64
+	// accessed is the UnixNano time of the last Get hit or of the store, updated atomically
+	accessed int64
+	key      interface{}
+	value    interface{}
 	//if tll is nil, entry is not expire auto
 	ttl *time.Time
+	// size reported by sizeOf in a sized cache
+	bytes int64
+	// number of slots taken toward the cache size, set by PutWeighted
+	weight int
+	// callback of this entry only, set by PutWithCallback
+	onEvict EvictCallback
+	// ttl duration the deadline was computed from, zero if the entry never expires
+	lifetime time.Duration
+	// miss marks a negative entry stored by PutMiss, it holds no value
+	miss bool
+	// metadata stored along with the value by PutWithMeta
+	meta interface{}
+	// version set by SetVersioned, 0 for the values stored without one
+	version uint64
+	// created is when the value was stored, its last Put or update
+	created time.Time
+	// epoch is the cache epoch when the value was stored, it is stale once Invalidate bumps it
+	epoch uint64
+	// stored marks a value read from the backing store, the write-behind doesn't write it back
+	stored bool
+	// pinned entries are not evicted to make room
+	pinned bool
 }
 
 func (e *entry) IsExpired() bool {
+	return e.expiredAt(time.Now())
+}
+
+// expiredAt returns true if the entry is expired at now
+func (e *entry) expiredAt(now time.Time) bool {
 	if e.ttl == nil {
 		return false
 	}
-	return time.Now().After(*e.ttl)
+	return now.After(*e.ttl)
+}
+
+// remaining returns the time from now until the entry expires, or NoExpiration
+func (e *entry) remaining(now time.Time) time.Duration {
+	if e.ttl == nil {
+		return NoExpiration
+	}
+	return e.ttl.Sub(now)
 }
 
-// NewLRUCache creates an expiring cache with the given size
-func NewLRUCache(maxSize int, ttl time.Duration, onEvict EvictCallback) (*LruCache, error) {
+// NewLRUCache creates an expiring cache with the given size.
+// A maxSize of Unbounded keeps every entry until it expires or is removed, in LRU order.
+// Such a cache grows without limit when the entries don't expire or expire slower than
+// they are added, and the expired ones are only removed when looked up or evicted,
+// so it should be paired with StartJanitor.
+func NewLRUCache(maxSize int, ttl time.Duration, onEvict EvictCallback, opts ...Option) (*LruCache, error) {
+	if maxSize == Unbounded {
+		maxSize = math.MaxInt
+	}
 	if maxSize <= 0 {
-		return nil, errors.New("Must provide a positive size to cache")
+		return nil, errNonPositiveSize
 	}
 	c := &LruCache{
 		size:      maxSize,
@@ -46,105 +326,1000 @@ func NewLRUCache(maxSize int, ttl time.Duration, onEvict EvictCallback) (*LruCac
 		cache:     make(map[interface{}]*list.Element),
 		ttl:       ttl,
 		onEvict:   onEvict,
+		clock:     realClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// NewLRUCacheWithEvictReason creates an expiring cache with the given size whose
+// callback also receives the reason of each eviction.
+func NewLRUCacheWithEvictReason(maxSize int, ttl time.Duration, onEvict EvictReasonCallback, opts ...Option) (*LruCache, error) {
+	c, err := NewLRUCache(maxSize, ttl, nil, opts...)
+	if err != nil {
+		return nil, err
 	}
+	c.onEvictReason = onEvict
 	return c, nil
 }
 
 // Get a key's value from the cache.
+// Get only takes the read lock, moving the entry to the front is deferred
+// until the next write or until the buffered hits fill up.
+// With a Loader set by WithLoader, a miss loads the value, ok is false if the load fails.
 func (c *LruCache) Get(key interface{}) (value interface{}, ok bool) {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	if value, ok = c.getCached(key); ok || c.loader == nil {
+		return value, ok
+	}
+	value, err := c.loadThrough(context.Background(), key)
+	return value, err == nil
+}
+
+// getCached gets a key's value from the cache without calling the Loader
+func (c *LruCache) getCached(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	if c.sliding {
+		c.lock.RUnlock()
+		return c.getSliding(key)
+	}
+	c.recordAccess(key)
 	//exsit
-	if ent, ok := c.cache[key]; ok {
-		//expired
-		if ent.Value.(*entry).IsExpired() {
-			c.removeElement(ent)
-			return nil, false
+	ent, ok := c.cache[key]
+	if !ok {
+		c.lock.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	//expired
+	if c.expired(ent.Value.(*entry)) {
+		c.lock.RUnlock()
+		c.removeExpired(key)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	//negative entry
+	if ent.Value.(*entry).miss {
+		c.lock.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	//not expired,movetofront later
+	value = ent.Value.(*entry).value
+	c.countHit(ent.Value.(*entry))
+	recorded := c.fifo || c.promote(ent)
+	refresh := c.refreshDue(ent.Value.(*entry))
+	c.lock.RUnlock()
+	atomic.AddUint64(&c.hits, 1)
+	if refresh {
+		c.refresh(key)
+	}
+	if !recorded {
+		// the buffer is full, apply it and move ent right away if it is still cached
+		c.writeLock()
+		if cur, ok := c.cache[key]; ok && cur == ent {
+			c.evictList.MoveToFront(ent)
+		}
+		c.lock.Unlock()
+	}
+	return value, true
+}
+
+// GetString gets a key's value like Get for a string key, without boxing the key on a hit.
+// Get takes its key as an interface{}, which allocates a copy of a string key unless it is
+// a constant. GetString only does so for the lookups Get needs the key for past a plain hit:
+// sliding expiration, the admission policy, refresh-ahead, misses and expired entries.
+// Storing a key boxes it anyway, so there is no string variant of Put.
+func (c *LruCache) GetString(key string) (value interface{}, ok bool) {
+	c.lock.RLock()
+	if c.sliding || c.admission != nil || c.reload != nil {
+		c.lock.RUnlock()
+		return c.Get(key)
+	}
+	ent, ok := c.cache[key]
+	if !ok || c.expired(ent.Value.(*entry)) || ent.Value.(*entry).miss {
+		c.lock.RUnlock()
+		return c.Get(key)
+	}
+	kv := ent.Value.(*entry)
+	value = kv.value
+	c.countHit(kv)
+	recorded := c.fifo || c.promote(ent)
+	c.lock.RUnlock()
+	atomic.AddUint64(&c.hits, 1)
+	if !recorded {
+		c.writeLock()
+		if cur, ok := c.cache[key]; ok && cur == ent {
+			c.evictList.MoveToFront(ent)
+		}
+		c.lock.Unlock()
+	}
+	return value, true
+}
+
+// getSliding gets a key's value and resets its deadline from now
+func (c *LruCache) getSliding(key interface{}) (value interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.get(key)
+}
+
+// TryGet gets a key's value like Get but never waits for the lock, acquired is false if it
+// is held and the key was not looked up, so the caller can fall back to the source instead.
+// This bounds the latency of a lookup, at the cost of more misses under contention.
+// The Loader is not called, and as TryGet can't remove an expired entry it is left for
+// the next write. Under the read lock a hit may skip its move to the front when many
+// concurrent hits are pending, with sliding expiration TryGet needs the write lock.
+func (c *LruCache) TryGet(key interface{}) (value interface{}, ok bool, acquired bool) {
+	if !c.lock.TryRLock() {
+		return nil, false, false
+	}
+	if c.sliding {
+		c.lock.RUnlock()
+		if !c.tryWriteLock() {
+			return nil, false, false
+		}
+		defer c.writeUnlock()
+		value, ok = c.get(key)
+		return value, ok, true
+	}
+	c.recordAccess(key)
+	ent, ok := c.cache[key]
+	if !ok || c.expired(ent.Value.(*entry)) || ent.Value.(*entry).miss {
+		c.lock.RUnlock()
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false, true
+	}
+	kv := ent.Value.(*entry)
+	value = kv.value
+	c.countHit(kv)
+	if !c.fifo {
+		// a full buffer drops the move rather than waiting for the write lock
+		c.promote(ent)
+	}
+	refresh := c.refreshDue(kv)
+	c.lock.RUnlock()
+	atomic.AddUint64(&c.hits, 1)
+	if refresh {
+		c.refresh(key)
+	}
+	return value, true, true
+}
+
+// get looks up a key's value and moves it to the front, the write lock must be held
+func (c *LruCache) get(key interface{}) (value interface{}, ok bool) {
+	c.recordAccess(key)
+	ent, ok := c.cache[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	if kv.miss {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	if c.sliding {
+		kv.ttl = c.deadline(kv)
+	}
+	c.moveToFront(ent)
+	c.countHit(kv)
+	atomic.AddUint64(&c.hits, 1)
+	return kv.value, true
+}
+
+// GetWithTTL gets a key's value from the cache along with its remaining lifetime,
+// or NoExpiration if the entry never expires.
+func (c *LruCache) GetWithTTL(key interface{}) (value interface{}, remaining time.Duration, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if value, ok = c.get(key); !ok {
+		return nil, 0, false
+	}
+	return value, c.cache[key].Value.(*entry).remaining(c.now()), true
+}
+
+// MGet looks up several keys under a single lock, returning the live ones.
+// The hits are moved to the front in the order of keys, so the last hit key
+// becomes the most recently used.
+func (c *LruCache) MGet(keys []interface{}) map[interface{}]interface{} {
+	c.writeLock()
+	defer c.writeUnlock()
+	found := make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			found[key] = value
+		}
+	}
+	return found
+}
+
+// GetMulti looks up several keys under a single lock like MGet, also returning the keys
+// absent or expired in the order of keys, so they can be loaded in one round-trip and
+// stored back with MPut. Keys stored by PutMiss are neither found nor missing.
+func (c *LruCache) GetMulti(keys []interface{}) (found map[interface{}]interface{}, missing []interface{}) {
+	c.writeLock()
+	defer c.writeUnlock()
+	found = make(map[interface{}]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			found[key] = value
+		} else if ent, ok := c.cache[key]; !ok || !ent.Value.(*entry).miss {
+			missing = append(missing, key)
+		}
+	}
+	return found, missing
+}
+
+// DisableRecencyUpdates sets whether accessing or updating an entry leaves its position
+// unchanged, which makes Get a pure read under the read lock. This changes the eviction
+// from LRU to FIFO: entries are evicted in insertion order.
+func (c *LruCache) DisableRecencyUpdates(disable bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.fifo = disable
+}
+
+// SetOnEvict replaces the eviction callback. Evictions happen under the write lock,
+// so each of them fires either the previous or the new callback.
+func (c *LruCache) SetOnEvict(onEvict EvictCallback) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.onEvict = onEvict
+}
+
+// SetOnExpire sets a callback fired instead of the eviction callback when an expired entry
+// is removed, by a lookup, an insert making room or FlushExpired, so expiry can be handled
+// apart from the evictions, the removals and Clear. A nil onExpire reports the expired
+// entries to the eviction callback again. The entry callbacks of PutWithCallback and the
+// callback aware of the reason are fired for the expired entries either way.
+func (c *LruCache) SetOnExpire(onExpire EvictCallback) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.onExpire = onExpire
+}
+
+// SetDefaultTTL changes the cache ttl used by the inserts which don't specify one.
+// The entries already cached keep their deadlines.
+func (c *LruCache) SetDefaultTTL(ttl time.Duration) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.ttl = ttl
+}
+
+// DefaultTTL returns the cache ttl used by the inserts which don't specify one.
+func (c *LruCache) DefaultTTL() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.ttl
+}
+
+// SetOnAdd sets a callback fired when a key absent from the cache is added, not when the
+// value of a cached key is replaced, including an expired one not removed yet by Put,
+// PutIfAbsent, ContainsOrPut or GetOrPut. It runs under the write lock, even with
+// WithAsyncEvict, and must not call the cache.
+func (c *LruCache) SetOnAdd(onAdd func(key, value interface{})) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.onAdd = onAdd
+}
+
+// SetSlidingExpiration sets whether a successful Get resets the entry deadline from now
+// using the ttl it was stored with, so only idle entries expire.
+// The default is to expire entries at a fixed deadline.
+func (c *LruCache) SetSlidingExpiration(sliding bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.sliding = sliding
+}
+
+// promote records a hit on e to be moved to the front, the read lock must be held.
+// It returns false if the promotion buffer is full and e was not recorded.
+func (c *LruCache) promote(e *list.Element) bool {
+	// readers finding the buffer full leave the counter alone, so that it can't wrap
+	// when only TryGet hits the cache between two write locks
+	if atomic.LoadInt32(&c.npromotions) >= maxPromotions {
+		return false
+	}
+	i := atomic.AddInt32(&c.npromotions, 1) - 1
+	if i >= maxPromotions {
+		return false
+	}
+	// each slot is written by a single reader and only read under the write lock
+	c.promotions[i] = e
+	return true
+}
+
+// writeLock takes the write lock and moves the elements hit by Get to the front
+func (c *LruCache) writeLock() {
+	c.lock.Lock()
+	c.applyPromotions()
+}
+
+// tryWriteLock is writeLock returning false instead of waiting if the lock is held
+func (c *LruCache) tryWriteLock() bool {
+	if !c.lock.TryLock() {
+		return false
+	}
+	c.applyPromotions()
+	return true
+}
+
+// applyPromotions moves the elements hit by Get to the front, the write lock must be held
+func (c *LruCache) applyPromotions() {
+	n := int(atomic.LoadInt32(&c.npromotions))
+	if n > maxPromotions {
+		n = maxPromotions
+	}
+	for i, e := range c.promotions[:n] {
+		// MoveToFront ignores the elements removed since the hit, the buffer is
+		// always applied before the list is reinitialized
+		c.evictList.MoveToFront(e)
+		c.promotions[i] = nil
+	}
+	atomic.StoreInt32(&c.npromotions, 0)
+}
+
+// writeUnlock releases the write lock, then fires the eviction callbacks deferred by
+// WithAsyncEvict or queues them to the workers of WithEvictWorkers, and calls onFull if an insert made the fill reach its threshold
+func (c *LruCache) writeUnlock() {
+	cb, n, size, onPanic := c.onFull, c.evictList.Len(), c.size, c.onPanic()
+	crossed := cb != nil && !c.full && c.overThreshold()
+	if crossed {
+		c.full = true
+	}
+	deferred := c.deferred
+	c.deferred = nil
+	c.lock.Unlock()
+	for _, ev := range deferred {
+		if c.evictWorkers != nil {
+			c.evictWorkers.dispatch(ev)
+		} else {
+			ev.fire()
 		}
-		//not expired,movetofront
-		c.evictList.MoveToFront(ent)
-		return ent.Value.(*entry).value, true
 	}
-	return nil, false
+	if crossed {
+		protect(onPanic, func() { cb(n, size) })
+	}
+}
+
+// moveToFront marks e as the most recently used unless the recency updates are disabled
+func (c *LruCache) moveToFront(e *list.Element) {
+	if !c.fifo {
+		c.evictList.MoveToFront(e)
+	}
+}
+
+// readLock takes the read lock once the pending promotions are applied,
+// so the evictList order reflects the previous Gets
+func (c *LruCache) readLock() {
+	if atomic.LoadInt32(&c.npromotions) > 0 {
+		c.writeLock()
+		c.lock.Unlock()
+	}
+	c.lock.RLock()
 }
 
 // removeElement is used to remove a given list element from the cache
-func (c *LruCache) removeElement(e *list.Element) {
+func (c *LruCache) removeElement(e *list.Element, reason EvictReason) {
+	kv := c.unlink(e)
+	switch reason {
+	case ReasonCapacity:
+		atomic.AddUint64(&c.evictions, 1)
+	case ReasonExpired:
+		atomic.AddUint64(&c.expirations, 1)
+	}
+	c.evicted(kv, reason)
+}
+
+// unlink removes e from the cache without firing any callback and returns its entry
+func (c *LruCache) unlink(e *list.Element) *entry {
 	c.evictList.Remove(e)
 	kv := e.Value.(*entry)
 	delete(c.cache, kv.key)
-	if c.onEvict != nil {
-		c.onEvict(kv.key, kv.value)
+	c.bytes -= kv.bytes
+	c.weight -= kv.weight
+	if kv.pinned {
+		c.pinned--
+	}
+	if c.full && !c.overThreshold() {
+		c.full = false
+	}
+	return kv
+}
+
+// eviction is an entry leaving the cache along with the callbacks to fire
+type eviction struct {
+	key, value    interface{}
+	reason        EvictReason
+	onEntryEvict  EvictCallback
+	onEvict       EvictCallback
+	onEvictReason EvictReasonCallback
+	onPanic       func(interface{})
+	// done is notified once the callbacks returned when set by Clear for the eviction workers
+	done *sync.WaitGroup
+}
+
+// evicted fires the eviction callbacks for kv, or defers them to writeUnlock with WithAsyncEvict
+// or WithEvictWorkers.
+// Negative entries don't fire any callback.
+func (c *LruCache) evicted(kv *entry, reason EvictReason) {
+	// negative entries hold no value to clean up
+	if kv.miss {
+		return
+	}
+	c.publish(EvictEvent{Key: kv.key, Value: kv.value, Reason: reason})
+	onEvict := c.onEvict
+	if reason == ReasonExpired && c.onExpire != nil {
+		onEvict = c.onExpire
+	}
+	ev := eviction{
+		key:           kv.key,
+		value:         kv.value,
+		reason:        reason,
+		onEntryEvict:  kv.onEvict,
+		onEvict:       onEvict,
+		onEvictReason: c.onEvictReason,
+		onPanic:       c.onPanic(),
+	}
+	if c.asyncEvict || c.evictWorkers != nil {
+		c.deferred = append(c.deferred, ev)
+		return
+	}
+	ev.fire()
+}
+
+// fire calls the callbacks of ev, the entry callback runs first, passing their panics to onPanic.
+// A replaced value is only reported to the entry callback and to the callback aware of the reason.
+func (ev *eviction) fire() {
+	if ev.onEntryEvict != nil {
+		protect(ev.onPanic, func() { ev.onEntryEvict(ev.key, ev.value) })
+	}
+	if ev.onEvict != nil && ev.reason != ReasonReplaced {
+		protect(ev.onPanic, func() { ev.onEvict(ev.key, ev.value) })
+	}
+	if ev.onEvictReason != nil {
+		protect(ev.onPanic, func() { ev.onEvictReason(ev.key, ev.value, ev.reason) })
 	}
 }
 
 // Add adds the value to the cache at key with the specified maximum duration.
+// A ttl of 0 or below uses the cache ttl, except NoExpiration which never expires.
 func (c *LruCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
-	c.lock.Lock()
-	defer c.lock.Unlock()
-	var ex *time.Time = nil
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.put(key, value, ttl)
+}
+
+// PutPermanent adds the value to the cache at key without expiry, like Put with NoExpiration.
+func (c *LruCache) PutPermanent(key, value interface{}) bool {
+	return c.Put(key, value, NoExpiration)
+}
+
+// MPut adds all the items to the cache under a single lock with the specified maximum duration.
+// Entries are evicted as each item is added, it returns the total number of evictions.
+func (c *LruCache) MPut(items map[interface{}]interface{}, ttl time.Duration) (evicted int) {
+	c.writeLock()
+	defer c.writeUnlock()
+	lifetime := c.lifetime(ttl)
+	for key, value := range items {
+		evicted += c.set(&entry{key: key, value: value, lifetime: lifetime})
+	}
+	return evicted
+}
+
+// PutWithCallback adds the value like Put, onEvict is called when this value leaves the cache
+// for any reason, including being replaced, before the cache callbacks.
+func (c *LruCache) PutWithCallback(key, value interface{}, ttl time.Duration, onEvict EvictCallback) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.set(&entry{key: key, value: value, lifetime: c.lifetime(ttl), onEvict: onEvict}) > 0
+}
+
+// PutIfAbsent adds the value only if the key is missing or expired, like sync.Map's LoadOrStore.
+// It returns the existing value and true if the key is present, without updating the recent-ness,
+// otherwise the stored value and false.
+func (c *LruCache) PutIfAbsent(key, value interface{}, ttl time.Duration) (actual interface{}, loaded bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent, ok := c.cache[key]; ok {
+		kv := ent.Value.(*entry)
+		// an expired value is replaced in place like Put does, so onAdd doesn't fire
+		if !kv.miss && !c.expired(kv) {
+			return kv.value, true
+		}
+	}
+	c.put(key, value, ttl)
+	return value, false
+}
+
+// PutWithMeta adds the value like Put along with meta, which GetWithMeta returns with the value.
+// A later Put of the same key drops meta.
+func (c *LruCache) PutWithMeta(key, value, meta interface{}, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.set(&entry{key: key, value: value, lifetime: c.lifetime(ttl), meta: meta}) > 0
+}
+
+// GetWithMeta gets a key's value from the cache like Get, along with the metadata
+// stored by PutWithMeta, nil if there is none.
+func (c *LruCache) GetWithMeta(key interface{}) (value, meta interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if value, ok = c.get(key); !ok {
+		return nil, nil, false
+	}
+	return value, c.cache[key].Value.(*entry).meta, true
+}
+
+// GetOrPut gets a key's value like Get, or adds value if the key is missing or expired.
+// It returns the existing value and true if the key is present, otherwise the stored value and false.
+// Unlike PutIfAbsent, a hit moves the entry to the front and counts in the stats, so reads keep it hot.
+// Both happen under a single lock, so concurrent callers see one created value, and either way
+// the key ends up the most recent, unless DisableRecencyUpdates is set. A new value may still
+// not be kept, when it is too large or rejected by WithFullPolicy or WithAdmissionPolicy.
+func (c *LruCache) GetOrPut(key, value interface{}, ttl time.Duration) (actual interface{}, loaded bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent, ok := c.cache[key]; ok && c.expired(ent.Value.(*entry)) {
+		// the expired value is replaced in place like Put does, so onAdd doesn't fire
+		atomic.AddUint64(&c.misses, 1)
+	} else if actual, loaded = c.get(key); loaded {
+		return actual, true
+	}
+	c.put(key, value, ttl)
+	return value, false
+}
+
+// ContainsOrPut checks if a live key exists without updating the recent-ness,
+// and adds the value otherwise. It returns true if the key was already there.
+func (c *LruCache) ContainsOrPut(key, value interface{}, ttl time.Duration) (existed bool) {
+	_, existed = c.PutIfAbsent(key, value, ttl)
+	return existed
+}
+
+// Update replaces the value of a live key while keeping its expiry deadline and metadata,
+// and moves it to the front. It returns false without inserting if the key is missing or expired,
+// and false if the new value is not cached, like a value too large for a sized cache which
+// removes the key, or an update evicting the key itself under DisableRecencyUpdates.
+func (c *LruCache) Update(key interface{}, value interface{}) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return false
+	}
+	if kv.miss {
+		return false
+	}
+	ex := kv.ttl
+	if _, err := c.add(&entry{key: key, value: value, lifetime: kv.lifetime, onEvict: kv.onEvict, weight: kv.weight, meta: kv.meta, version: kv.version}); err != nil {
+		return false
+	}
+	if c.cache[key] != ent {
+		return false
+	}
+	kv.ttl = ex
+	return true
+}
+
+// ReplaceIf replaces the value of a live key with new only if its current value is old,
+// storing it with the specified maximum duration like Update keeps the metadata. The values
+// are compared with ==, so pointers match by address and two values of the same uncomparable
+// type, like slices, never match. It returns true if the value was replaced.
+func (c *LruCache) ReplaceIf(key, old, new interface{}, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return false
+	}
+	if kv.miss || !equalValues(kv.value, old) {
+		return false
+	}
+	c.set(&entry{key: key, value: new, lifetime: c.lifetime(ttl), onEvict: kv.onEvict, weight: kv.weight, meta: kv.meta, version: kv.version})
+	return true
+}
+
+// equalValues returns a == b, false if they can't be compared
+func equalValues(a, b interface{}) (eq bool) {
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return a == b
+}
+
+// Touch resets the expiry deadline of a live key from now, using ttl or the cache
+// ttl if ttl <= 0, and moves it to the front. It returns false if the key is missing, expired
+// or negative.
+func (c *LruCache) Touch(key interface{}, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return false
+	}
+	if kv.miss {
+		return false
+	}
+	kv.lifetime = c.lifetime(ttl)
+	kv.ttl = c.deadline(kv)
+	c.moveToFront(ent)
+	return true
+}
+
+// ExtendTTL adds delta to the expiry deadline of a live key without updating the recent-ness,
+// a key which never expires gets a deadline delta from now. The deadline is still capped by
+// the WithMaxTTL option from when the key was stored. It returns false if the key is missing, expired or negative.
+func (c *LruCache) ExtendTTL(key interface{}, delta time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return false
+	}
+	if kv.miss {
+		return false
+	}
+	if c.noExpiry {
+		return true
+	}
+	now := c.now()
+	ex := now.Add(delta)
+	if kv.ttl != nil {
+		ex = kv.ttl.Add(delta)
+	}
+	if limit := kv.created.Add(c.maxTTL); c.maxTTL > 0 && ex.After(limit) {
+		ex = limit
+	}
+	kv.ttl = &ex
+	return true
+}
+
+// lifetime returns the ttl of a new entry, zero if it never expires.
+// NoExpiration never expires, other ttl <= 0 fall back to the cache ttl.
+func (c *LruCache) lifetime(ttl time.Duration) time.Duration {
+	if ttl == NoExpiration || c.noExpiry {
+		return 0
+	}
 	if ttl > 0 {
-		expire := time.Now().Add(ttl)
-		ex = &expire
+		return ttl
 	} else if c.ttl > 0 {
-		expire := time.Now().Add(c.ttl)
-		ex = &expire
+		return c.ttl
+	}
+	return 0
+}
+
+// deadline returns the expiry time of e living for its lifetime from now, nil if it never expires.
+// The deadline is capped by maxTTL from when e was stored.
+func (c *LruCache) deadline(e *entry) *time.Time {
+	if c.noExpiry || (e.lifetime <= 0 && c.maxTTL <= 0) {
+		return nil
+	}
+	var expire time.Time
+	if e.lifetime > 0 {
+		expire = c.now().Add(e.lifetime)
+	}
+	if limit := e.created.Add(c.maxTTL); c.maxTTL > 0 && (e.lifetime <= 0 || expire.After(limit)) {
+		expire = limit
+	}
+	return &expire
+}
+
+// put adds the value to the cache, the lock must be held
+func (c *LruCache) put(key interface{}, value interface{}, ttl time.Duration) bool {
+	return c.set(&entry{key: key, value: value, lifetime: c.lifetime(ttl)}) > 0
+}
+
+// set adds e to the cache, replacing the entry of the same key, the lock must be held.
+// It returns the number of evicted entries.
+func (c *LruCache) set(e *entry) int {
+	evicted, _ := c.add(e)
+	return evicted
+}
+
+// add is set reporting why e was not cached, the lock must be held.
+// The deadline and size of e are computed from its lifetime and value, a zero weight counts as 1.
+// It returns the number of evicted entries.
+func (c *LruCache) add(e *entry) (int, error) {
+	e.created = c.now()
+	e.accessed = e.created.UnixNano()
+	e.epoch = c.epoch
+	e.ttl = c.deadline(e)
+	if e.weight <= 0 {
+		e.weight = 1
+	}
+	if c.sizeOf != nil && !e.miss {
+		bytes, err := c.entrySize(e)
+		if err != nil {
+			return 0, err
+		}
+		e.bytes = bytes
+	}
+	// a value that can never fit is not cached
+	if e.weight > c.size || (c.sizeOf != nil && e.bytes > c.maxBytes) {
+		if ent, ok := c.cache[e.key]; ok {
+			c.removeElement(ent, ReasonRemoved)
+		}
+		return 0, ErrTooLarge
 	}
 	//Check for existing item
-	if ent, ok := c.cache[key]; ok {
-		c.evictList.MoveToFront(ent)
-		ent.Value.(*entry).value = value
-		ent.Value.(*entry).ttl = ex
-		return false
+	if ent, ok := c.cache[e.key]; ok {
+		c.moveToFront(ent)
+		kv := ent.Value.(*entry)
+		c.evicted(kv, ReasonReplaced)
+		c.bytes += e.bytes - kv.bytes
+		c.weight += e.weight - kv.weight
+		// the hits and the pin of the key are kept across updates
+		e.hits, e.pinned = kv.hits, kv.pinned
+		*kv = *e
+		c.queueWrite(e)
+		return c.evictOverflow(ent), nil
 	}
 	// Add new item
-	ent := &entry{
-		key:   key,
-		value: value,
-		ttl:   ex,
-	}
-	entry := c.evictList.PushFront(ent)
-	c.cache[key] = entry
-	evict := c.evictList.Len() > c.size
+	if c.fullPolicy == PolicyReject {
+		// only the expired entries make room
+		for !c.fits(e) {
+			ent := c.oldestExpired()
+			if ent == nil {
+				return 0, ErrCacheFull
+			}
+			c.removeElement(ent, ReasonExpired)
+		}
+	}
+	if !c.admit(e) {
+		return 0, nil
+	}
+	entry := c.evictList.PushFront(e)
+	c.cache[e.key] = entry
+	c.bytes += e.bytes
+	c.weight += e.weight
+	if c.onAdd != nil && !e.miss {
+		protect(c.onPanic(), func() { c.onAdd(e.key, e.value) })
+	}
+	c.queueWrite(e)
+	return c.evictOverflow(entry), nil
+}
+
+// evictOverflow removes the oldest entries until the cache fits its size after added was stored,
+// it returns the number of evicted entries
+func (c *LruCache) evictOverflow(added *list.Element) (evicted int) {
 	// Verify size not exceeded
-	if evict {
-		c.removeOldest()
+	for c.weight > c.size || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		if !c.removeOldest(added) {
+			break
+		}
+		evicted++
+	}
+	// make headroom for the next inserts, never down to added alone
+	for evicted > 0 && evicted < c.evictBatch && c.evictList.Len()-c.pinned > 1 {
+		if !c.removeOldest(added) {
+			break
+		}
+		evicted++
+	}
+	return evicted
+}
+
+// removeOldest removes the oldest unpinned item from the cache, or the newest one with MRU,
+// or an expired one among the maxExpiredScan oldest if there is one.
+// The MRU eviction keeps added, the element just stored, unless it is the only candidate.
+// It returns false if there is nothing to remove, all the entries are pinned.
+func (c *LruCache) removeOldest(added *list.Element) bool {
+	if e := c.oldestExpired(); e != nil {
+		c.removeElement(e, ReasonExpired)
+		return true
+	}
+	if c.policy == MRU {
+		for e := c.evictList.Front(); e != nil; e = e.Next() {
+			if e != added && !e.Value.(*entry).pinned {
+				c.removeElement(e, ReasonCapacity)
+				return true
+			}
+		}
+		if added != nil && !added.Value.(*entry).pinned {
+			c.removeElement(added, ReasonCapacity)
+			return true
+		}
+		return false
 	}
-	return evict
+	for e := c.evictList.Back(); e != nil; e = e.Prev() {
+		if !e.Value.(*entry).pinned {
+			c.removeElement(e, ReasonCapacity)
+			return true
+		}
+	}
+	return false
 }
 
-// removeOldest removes the oldest item from the cache
-func (c *LruCache) removeOldest() {
-	ent := c.evictList.Back()
-	if ent != nil {
-		c.removeElement(ent)
+// oldestExpired returns the oldest expired entry among the maxExpiredScan oldest, or nil
+func (c *LruCache) oldestExpired() *list.Element {
+	if c.noExpiry {
+		return nil
+	}
+	for e, i := c.evictList.Back(), 0; e != nil && i < maxExpiredScan; e, i = e.Prev(), i+1 {
+		if c.expired(e.Value.(*entry)) {
+			return e
+		}
+	}
+	return nil
+}
+
+// fits returns true if e can be added without evicting anything, the lock must be held
+func (c *LruCache) fits(e *entry) bool {
+	return c.weight+e.weight <= c.size && (c.maxBytes == 0 || c.bytes+e.bytes <= c.maxBytes)
+}
+
+// Resize changes the cache size, evicting the oldest entries if the cache
+// holds more than newSize items, or a larger total weight. It returns the number of evicted entries.
+// The pinned entries are kept even if they don't fit in newSize.
+// Resizing to Unbounded stops the evictions to make room.
+func (c *LruCache) Resize(newSize int) (evicted int, err error) {
+	if newSize == Unbounded {
+		newSize = math.MaxInt
+	}
+	if newSize <= 0 {
+		return 0, errNonPositiveSize
+	}
+	c.writeLock()
+	defer c.writeUnlock()
+	for c.weight > newSize {
+		if !c.removeOldest(nil) {
+			break
+		}
+		evicted++
 	}
+	c.size = newSize
+	return evicted, nil
+}
+
+// Cap returns the maximum number of items in the cache, or their maximum total weight,
+// math.MaxInt for an Unbounded cache.
+func (c *LruCache) Cap() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.size
 }
 
 // Len returns the number of items in the cache.
+// It is cheap but counts the expired entries not removed yet and the negative ones,
+// use LenLive for the number of entries Get would return.
 func (c *LruCache) Len() int {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 	return c.evictList.Len()
 }
 
+// LenLive returns the number of live entries, skipping the expired and negative ones.
+// It walks the whole cache, so it is O(n) unlike Len.
+func (c *LruCache) LenLive() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	n := 0
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		kv := ent.Value.(*entry)
+		if !c.expired(kv) && !kv.miss {
+			n++
+		}
+	}
+	return n
+}
+
 // Remove removes the provided key from the cache.
 func (c *LruCache) Remove(key interface{}) bool {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	c.writeLock()
+	defer c.writeUnlock()
 	if ent, ok := c.cache[key]; ok {
-		c.removeElement(ent)
+		c.removeElement(ent, ReasonRemoved)
 		return true
 	}
 	return false
 }
 
+// RemoveFunc removes all the entries for which match returns true, firing onEvict,
+// and returns how many were removed. Negative entries are matched with a nil value.
+// match runs with the write lock held and must not call the cache. Unlike removing the
+// keys returned by Keys, no write can slip in between matching an entry and removing it.
+func (c *LruCache) RemoveFunc(match func(key, value interface{}) bool) int {
+	c.writeLock()
+	defer c.writeUnlock()
+	removed := 0
+	for ent := c.evictList.Back(); ent != nil; {
+		prev := ent.Prev()
+		kv := ent.Value.(*entry)
+		if match(kv.key, kv.value) {
+			c.removeElement(ent, ReasonRemoved)
+			removed++
+		}
+		ent = prev
+	}
+	return removed
+}
+
+// RemoveOlderThan removes all the entries whose value was stored more than age ago, whatever
+// their ttl, firing onEvict, and returns how many were removed. A value replaced by Put or
+// an update like Update counts from its replacement, Touch and ExtendTTL don't change it.
+func (c *LruCache) RemoveOlderThan(age time.Duration) int {
+	c.writeLock()
+	defer c.writeUnlock()
+	cutoff := c.now().Add(-age)
+	removed := 0
+	for ent := c.evictList.Back(); ent != nil; {
+		prev := ent.Prev()
+		if ent.Value.(*entry).created.Before(cutoff) {
+			c.removeElement(ent, ReasonRemoved)
+			removed++
+		}
+		ent = prev
+	}
+	return removed
+}
+
+// RemovePrefix removes all the entries whose key is a string starting with prefix,
+// firing onEvict, and returns how many were removed. Other keys are left alone.
+func (c *LruCache) RemovePrefix(prefix string) int {
+	return c.RemoveFunc(func(key, value interface{}) bool {
+		k, ok := key.(string)
+		return ok && strings.HasPrefix(k, prefix)
+	})
+}
+
+// GetAndRemove gets a key's value and removes it from the cache in one step, so only
+// one caller gets it. The value is handed to the caller, no eviction callback fires.
+// An expired entry is removed and reported as missing.
+func (c *LruCache) GetAndRemove(key interface{}) (value interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return nil, false
+	}
+	if kv.miss {
+		return nil, false
+	}
+	c.unlink(ent)
+	return kv.value, true
+}
+
 // Contains Check if a key exsists in cache without updating the recent-ness.
 func (c *LruCache) Contains(key interface{}) (ok bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 	if ent, ok := c.cache[key]; ok {
-		if ent.Value.(*entry).IsExpired() {
+		if c.expired(ent.Value.(*entry)) || ent.Value.(*entry).miss {
 			return false
 		}
 		return ok
@@ -152,28 +1327,351 @@ func (c *LruCache) Contains(key interface{}) (ok bool) {
 	return false
 }
 
-// Keys return all the keys in cache, from oldest to newest
-func (c *LruCache) Keys() []interface{} {
+// Peek returns a key's value without updating the recent-ness.
+// An expired entry is removed and reported as missing.
+func (c *LruCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.RLock()
+	ent, ok := c.cache[key]
+	if !ok {
+		c.lock.RUnlock()
+		return nil, false
+	}
+	if c.expired(ent.Value.(*entry)) {
+		c.lock.RUnlock()
+		c.removeExpired(key)
+		return nil, false
+	}
+	value, ok = ent.Value.(*entry).value, !ent.Value.(*entry).miss
+	c.lock.RUnlock()
+	return value, ok
+}
+
+// TTL returns the remaining lifetime of a key without updating the recent-ness,
+// or NoExpiration if the entry never expires. ok is false if the key is missing, expired or negative.
+func (c *LruCache) TTL(key interface{}) (remaining time.Duration, ok bool) {
 	c.lock.RLock()
+	ent, ok := c.cache[key]
+	if !ok {
+		c.lock.RUnlock()
+		return 0, false
+	}
+	if c.expired(ent.Value.(*entry)) {
+		c.lock.RUnlock()
+		c.removeExpired(key)
+		return 0, false
+	}
+	if ent.Value.(*entry).miss {
+		c.lock.RUnlock()
+		return 0, false
+	}
+	remaining = ent.Value.(*entry).remaining(c.now())
+	c.lock.RUnlock()
+	return remaining, true
+}
+
+// removeExpired takes the write lock and removes key if it is still expired
+func (c *LruCache) removeExpired(key interface{}) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent, ok := c.cache[key]; ok && c.expired(ent.Value.(*entry)) {
+		c.removeElement(ent, ReasonExpired)
+	}
+}
+
+// GetOldest returns the least recently used entry without updating the recent-ness.
+// With LRU it is the next to be evicted unless it is pinned, while with MRU the next is
+// the one GetNewest returns. Expired entries are removed on the way.
+func (c *LruCache) GetOldest() (key, value interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent := c.oldest(); ent != nil {
+		kv := ent.Value.(*entry)
+		return kv.key, kv.value, true
+	}
+	return nil, nil, false
+}
+
+// GetNewest returns the most recently used entry without updating the recent-ness.
+// Expired entries are removed on the way.
+func (c *LruCache) GetNewest() (key, value interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent := c.newest(); ent != nil {
+		kv := ent.Value.(*entry)
+		return kv.key, kv.value, true
+	}
+	return nil, nil, false
+}
+
+// RemoveOldest removes and returns the oldest entry, firing onEvict.
+// Expired entries are removed on the way.
+func (c *LruCache) RemoveOldest() (key, value interface{}, ok bool) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent := c.oldest(); ent != nil {
+		kv := ent.Value.(*entry)
+		c.removeElement(ent, ReasonRemoved)
+		return kv.key, kv.value, true
+	}
+	return nil, nil, false
+}
+
+// oldest returns the oldest live entry with a value, removing the expired ones, the write lock must be held
+func (c *LruCache) oldest() *list.Element {
+	for ent := c.evictList.Back(); ent != nil; {
+		kv := ent.Value.(*entry)
+		prev := ent.Prev()
+		if c.expired(kv) {
+			c.removeElement(ent, ReasonExpired)
+		} else if !kv.miss {
+			return ent
+		}
+		ent = prev
+	}
+	return nil
+}
+
+// newest returns the newest live entry with a value, removing the expired ones, the write lock must be held
+func (c *LruCache) newest() *list.Element {
+	for ent := c.evictList.Front(); ent != nil; {
+		kv := ent.Value.(*entry)
+		next := ent.Next()
+		if c.expired(kv) {
+			c.removeElement(ent, ReasonExpired)
+		} else if !kv.miss {
+			return ent
+		}
+		ent = next
+	}
+	return nil
+}
+
+// Keys return all the live keys in cache, from oldest to newest.
+// Expired and negative entries are skipped. The slice is a copy taken under a single lock,
+// a consistent point-in-time view which the later writes don't change, but by the time
+// it is used another goroutine may have removed or added keys. To remove the keys
+// matching a condition, RemoveFunc checks and removes them under the same lock.
+func (c *LruCache) Keys() []interface{} {
+	c.readLock()
 	defer c.lock.RUnlock()
-	keys := make([]interface{}, len(c.cache))
-	i := 0
+	keys := make([]interface{}, 0, len(c.cache))
 	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
-		keys[i] = ent.Value.(*entry).key
-		i++
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		keys = append(keys, kv.key)
 	}
 	return keys
 }
 
-// Clear remove all the keys in cache
+// KeysNewestFirst returns all the live keys in cache from newest to oldest, the reverse of Keys,
+// for example to list the recently used keys. Like Keys it is a copy taken under the read lock.
+func (c *LruCache) KeysNewestFirst() []interface{} {
+	c.readLock()
+	defer c.lock.RUnlock()
+	return c.keys(len(c.cache), c.evictList.Front(), (*list.Element).Next)
+}
+
+// RecentKeys returns up to n live keys from newest to oldest, without updating the recent-ness.
+func (c *LruCache) RecentKeys(n int) []interface{} {
+	c.readLock()
+	defer c.lock.RUnlock()
+	return c.keys(n, c.evictList.Front(), (*list.Element).Next)
+}
+
+// OldestKeys returns up to n live keys from oldest to newest, without updating the recent-ness.
+func (c *LruCache) OldestKeys(n int) []interface{} {
+	c.readLock()
+	defer c.lock.RUnlock()
+	return c.keys(n, c.evictList.Back(), (*list.Element).Prev)
+}
+
+// keys returns up to n live keys walking the list from ent with next, the lock must be held
+func (c *LruCache) keys(n int, ent *list.Element, next func(*list.Element) *list.Element) []interface{} {
+	if n > len(c.cache) {
+		n = len(c.cache)
+	}
+	if n <= 0 {
+		return nil
+	}
+	keys := make([]interface{}, 0, n)
+	for ; ent != nil && len(keys) < n; ent = next(ent) {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		keys = append(keys, kv.key)
+	}
+	return keys
+}
+
+// Range calls f for each live entry from oldest to newest without updating the recent-ness,
+// stopping if f returns false. f runs with the read lock held and must not call the cache.
+func (c *LruCache) Range(f func(key, value interface{}) bool) {
+	c.readLock()
+	defer c.lock.RUnlock()
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Clear remove all the keys in cache, firing onEvict for each entry.
+// Use Purge to empty the cache without running the callbacks.
+// With WithEvictWorkers it returns once the callbacks of the removed entries returned.
 func (c *LruCache) Clear() {
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	var done sync.WaitGroup
+	// waits for the eviction workers once the callbacks are queued by writeUnlock
+	defer done.Wait()
+	c.writeLock()
+	defer c.writeUnlock()
+	start := len(c.deferred)
+	for _, v := range c.cache {
+		c.evicted(v.Value.(*entry), ReasonCleared)
+	}
+	if c.evictWorkers != nil {
+		for i := range c.deferred[start:] {
+			c.deferred[start+i].done = &done
+			done.Add(1)
+		}
+	}
+	c.reset()
+}
+
+// Invalidate makes all the entries in cache stale in constant time, the lookups treat them
+// as expired from then on. Unlike Clear they are not removed at once but like the other
+// expired entries, when looked up, evicted first to make room or flushed by the janitor,
+// and they fire the callbacks of expired entries then. WithoutExpiry doesn't keep them.
+func (c *LruCache) Invalidate() {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.epoch++
+}
+
+// Purge remove all the keys in cache without firing any callback, for example
+// to drop the cache on shutdown without running the cleanup of evicted entries.
+func (c *LruCache) Purge() {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.reset()
+}
+
+// Drain removes all the keys in cache and returns the live entries, all under the write lock,
+// so no drained entry can be served by the cache afterwards. Like Purge it fires no callback,
+// the negative entries are dropped.
+func (c *LruCache) Drain() map[interface{}]interface{} {
+	c.writeLock()
+	defer c.writeUnlock()
+	items := make(map[interface{}]interface{}, len(c.cache))
 	for k, v := range c.cache {
-		if c.onEvict != nil {
-			c.onEvict(k, v.Value.(*entry).value)
+		if kv := v.Value.(*entry); !kv.miss && !c.expired(kv) {
+			items[k] = kv.value
 		}
+	}
+	c.reset()
+	return items
+}
+
+// reset empties the cache without firing any callback, the write lock must be held
+func (c *LruCache) reset() {
+	for k := range c.cache {
 		delete(c.cache, k)
 	}
 	c.evictList.Init()
+	c.bytes = 0
+	c.weight = 0
+	c.pinned = 0
+	c.full = false
+}
+
+// Snapshot returns an independent copy of the cache holding its live entries in the
+// same order with their current expiry deadlines, along with its size, ttl and settings.
+// The copy has no eviction callbacks, neither the cache ones nor the entry ones,
+// so it can be mutated without side effects, and its stats start from zero.
+func (c *LruCache) Snapshot() *LruCache {
+	// the write lock keeps the hit counts from changing while the entries are copied
+	c.writeLock()
+	defer c.writeUnlock()
+	s := &LruCache{
+		size:        c.size,
+		evictList:   list.New(),
+		cache:       make(map[interface{}]*list.Element, len(c.cache)),
+		ttl:         c.ttl,
+		sliding:     c.sliding,
+		fifo:        c.fifo,
+		maxBytes:    c.maxBytes,
+		sizeOf:      c.sizeOf,
+		clock:       c.clock,
+		maxTTL:      c.maxTTL,
+		policy:      c.policy,
+		noExpiry:    c.noExpiry,
+		evictBatch:  c.evictBatch,
+		epoch:       c.epoch,
+		fullPolicy:  c.fullPolicy,
+		noHitCounts: c.noHitCounts,
+		accessTimes: c.accessTimes,
+	}
+	if c.admission != nil {
+		s.admission = newSketch(c.size)
+	}
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) {
+			continue
+		}
+		e := *kv
+		e.onEvict = nil
+		s.cache[e.key] = s.evictList.PushBack(&e)
+		s.bytes += e.bytes
+		s.weight += e.weight
+		if e.pinned {
+			s.pinned++
+		}
+	}
+	return s
+}
+
+// Merge adds the live entries of other to the cache from oldest to newest, so the newest
+// of other end up the most recent, with the remaining lifetime they have in other, their
+// weight and metadata. Unless overwrite is set, the keys holding a live value in the cache
+// are left alone. The cache size is respected, the entries evicted to make room fire the
+// callbacks of the cache, while the entry callbacks set in other are not copied.
+// other is read under its own lock before the cache is written.
+func (c *LruCache) Merge(other *LruCache, overwrite bool) {
+	if other == c {
+		return
+	}
+	other.readLock()
+	entries := make([]entry, 0, len(other.cache))
+	now := other.now()
+	for ent := other.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if kv.miss || other.expired(kv) {
+			continue
+		}
+		var lifetime time.Duration
+		if kv.ttl != nil {
+			lifetime = kv.remaining(now)
+		}
+		entries = append(entries, entry{key: kv.key, value: kv.value, lifetime: lifetime, weight: kv.weight, meta: kv.meta, version: kv.version})
+	}
+	other.lock.RUnlock()
+
+	c.writeLock()
+	defer c.writeUnlock()
+	for i := range entries {
+		e := &entries[i]
+		if ent, ok := c.cache[e.key]; ok && !overwrite {
+			if kv := ent.Value.(*entry); !kv.miss && !c.expired(kv) {
+				continue
+			}
+		}
+		c.set(e)
+	}
 }
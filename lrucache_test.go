@@ -1,6 +1,10 @@
 package lrucache
 
 import (
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -111,3 +115,1889 @@ func TestLRU_Contains(t *testing.T) {
 		t.Errorf("Contains should not have updated recent-ness of 1")
 	}
 }
+
+// Test that Peek doesn't update recent-ness
+func TestLRU_Peek(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	if v, ok := l.Peek(1); !ok || v != 1 {
+		t.Errorf("1 should be set to 1: %v, %v", v, ok)
+	}
+	l.Put(3, 3, Expired)
+	if l.Contains(1) {
+		t.Errorf("should not have updated recent-ness of 1")
+	}
+}
+
+// Test that Peek removes expired entries
+func TestLRU_PeekExpired(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(2, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := l.Peek(1); ok {
+		t.Errorf("1 should be expired")
+	}
+	if l.Len() != 0 || evictCounter != 1 {
+		t.Errorf("expired entry should be removed, len: %v, evict count: %v", l.Len(), evictCounter)
+	}
+}
+
+func TestLRU_Resize(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(4, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	evicted, err := l.Resize(2)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if evicted != 2 || evictCounter != 2 || l.Len() != 2 {
+		t.Fatalf("bad resize, evicted: %v, evict count: %v, len: %v", evicted, evictCounter, l.Len())
+	}
+	if l.Contains(0) || l.Contains(1) || !l.Contains(3) {
+		t.Fatalf("oldest entries should be evicted")
+	}
+
+	if evicted, _ := l.Resize(8); evicted != 0 {
+		t.Fatalf("growing should not evict: %v", evicted)
+	}
+	for i := 0; i < 8; i++ {
+		l.Put(i, i, Expired)
+	}
+	if l.Len() != 8 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+
+	if _, err := l.Resize(0); err == nil {
+		t.Fatalf("should reject a zero size")
+	}
+}
+
+func TestLRU_GetOldestNewest(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, ok := l.GetOldest(); ok {
+		t.Fatalf("empty cache should have no oldest")
+	}
+	if _, _, ok := l.GetNewest(); ok {
+		t.Fatalf("empty cache should have no newest")
+	}
+
+	l.Put(0, 0, 10*time.Millisecond)
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	if k, v, ok := l.GetOldest(); !ok || k != 1 || v != 1 {
+		t.Fatalf("bad oldest: %v, %v", k, v)
+	}
+	if k, v, ok := l.GetNewest(); !ok || k != 2 || v != 2 {
+		t.Fatalf("bad newest: %v, %v", k, v)
+	}
+	if l.Len() != 2 {
+		t.Fatalf("expired entries should be removed: %v", l.Len())
+	}
+	// recent-ness is not updated
+	if k, _, _ := l.GetOldest(); k != 1 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+}
+
+func TestLRU_EvictReason(t *testing.T) {
+	reasons := make(map[interface{}]EvictReason)
+	onEvicted := func(k interface{}, v interface{}, reason EvictReason) {
+		reasons[k] = reason
+	}
+	l, err := NewLRUCacheWithEvictReason(2, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(1, 2, Expired)
+	if reasons[1] != ReasonReplaced {
+		t.Fatalf("bad reason for 1: %v", reasons[1])
+	}
+	l.Put(2, 2, 10*time.Millisecond)
+	l.Put(3, 3, Expired)
+	if reasons[1] != ReasonCapacity {
+		t.Fatalf("bad reason for 1: %v", reasons[1])
+	}
+	time.Sleep(20 * time.Millisecond)
+	l.Get(2)
+	if reasons[2] != ReasonExpired {
+		t.Fatalf("bad reason for 2: %v", reasons[2])
+	}
+	l.Put(4, 4, Expired)
+	l.Remove(3)
+	if reasons[3] != ReasonRemoved {
+		t.Fatalf("bad reason for 3: %v", reasons[3])
+	}
+	l.Clear()
+	if reasons[4] != ReasonCleared {
+		t.Fatalf("bad reason for 4: %v", reasons[4])
+	}
+}
+
+func TestLRU_PutIfAbsent(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, loaded := l.PutIfAbsent(1, 1, Expired); loaded || v != 1 {
+		t.Fatalf("1 should be stored: %v, %v", v, loaded)
+	}
+	if v, loaded := l.PutIfAbsent(1, 2, Expired); !loaded || v != 1 {
+		t.Fatalf("1 should be loaded: %v, %v", v, loaded)
+	}
+	if v, _ := l.Get(1); v != 1 {
+		t.Fatalf("1 should not be overwritten: %v", v)
+	}
+
+	l.Put(2, 2, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if v, loaded := l.PutIfAbsent(2, 3, Expired); loaded || v != 3 {
+		t.Fatalf("expired 2 should be overwritten: %v, %v", v, loaded)
+	}
+	if v, ok := l.Get(2); !ok || v != 3 {
+		t.Fatalf("bad value for 2: %v", v)
+	}
+}
+
+func TestLRU_GetOrPut(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v, loaded := l.GetOrPut(1, 1, Expired); loaded || v != 1 {
+		t.Fatalf("1 should be stored: %v, %v", v, loaded)
+	}
+	l.Put(2, 2, Expired)
+	if v, loaded := l.GetOrPut(1, 10, Expired); !loaded || v != 1 {
+		t.Fatalf("1 should be loaded: %v, %v", v, loaded)
+	}
+	// the hit on 1 made 2 the oldest
+	l.Put(3, 3, Expired)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("2 should be evicted")
+	}
+
+	l.Put(3, 3, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if v, loaded := l.GetOrPut(3, 30, Expired); loaded || v != 30 {
+		t.Fatalf("expired 3 should be overwritten: %v, %v", v, loaded)
+	}
+	if s := l.Stats(); s.Hits != 1 || s.Misses != 2 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestLRU_GetOrPutConcurrent(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	var created int32
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			v, loaded := l.GetOrPut(3, g, Expired)
+			if !loaded {
+				atomic.AddInt32(&created, 1)
+			}
+			if cur, _ := l.Peek(3); cur != v {
+				t.Errorf("bad value: %v != %v", v, cur)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Fatalf("3 should be created once: %v", created)
+	}
+	// a hit or an insert leaves the key the most recent
+	if keys := l.RecentKeys(1); keys[0] != 3 {
+		t.Fatalf("bad recent keys: %v", keys)
+	}
+	l.GetOrPut(1, 10, Expired)
+	if keys := l.RecentKeys(1); keys[0] != 1 {
+		t.Fatalf("bad recent keys: %v", keys)
+	}
+}
+
+func TestLRU_ContainsOrPut(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if l.ContainsOrPut(1, 1, Expired) {
+		t.Fatalf("1 should be stored")
+	}
+	if !l.ContainsOrPut(1, 2, Expired) {
+		t.Fatalf("1 should exist")
+	}
+	if v, _ := l.Get(1); v != 1 {
+		t.Fatalf("1 should not be overwritten: %v", v)
+	}
+
+	l.Put(2, 2, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if l.ContainsOrPut(2, 3, Expired) {
+		t.Fatalf("expired 2 should be overwritten")
+	}
+	if v, ok := l.Get(2); !ok || v != 3 {
+		t.Fatalf("bad value for 2: %v", v)
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 0)
+	l.Put(3, 3, 10*time.Millisecond)
+	if d, ok := l.TTL(1); !ok || d <= 0 || d > Expired {
+		t.Fatalf("bad ttl for 1: %v, %v", d, ok)
+	}
+	if d, ok := l.TTL(2); !ok || d != NoExpiration {
+		t.Fatalf("2 should never expire: %v, %v", d, ok)
+	}
+	if _, ok := l.TTL(4); ok {
+		t.Fatalf("4 should be missing")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := l.TTL(3); ok {
+		t.Fatalf("3 should be expired")
+	}
+}
+
+func TestLRU_Update(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if l.Update(1, 1) || l.Contains(1) {
+		t.Fatalf("Update should not insert")
+	}
+	l.Put(1, 1, 50*time.Millisecond)
+	l.Put(2, 2, Expired)
+	before, _ := l.TTL(1)
+	if !l.Update(1, 10) {
+		t.Fatalf("1 should be updated")
+	}
+	if v, _ := l.Peek(1); v != 10 {
+		t.Fatalf("bad value for 1: %v", v)
+	}
+	if after, _ := l.TTL(1); after > before {
+		t.Fatalf("expiry should be kept: %v > %v", after, before)
+	}
+	// Update moves to front
+	l.Put(3, 3, Expired)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("Update should have updated recent-ness of 1")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if l.Update(1, 11) {
+		t.Fatalf("expired 1 should not be updated")
+	}
+}
+
+func TestLRU_Touch(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if l.Touch(1, Expired) {
+		t.Fatalf("missing key should not be touched")
+	}
+	l.Put(1, 1, 20*time.Millisecond)
+	l.Put(2, 2, Expired)
+	if !l.Touch(1, Expired) {
+		t.Fatalf("1 should be touched")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("touched 1 should not expire")
+	}
+	// Touch moves to front
+	l.Touch(2, 0)
+	l.Put(3, 3, Expired)
+	if l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("Touch should have updated recent-ness of 2")
+	}
+
+	l.Put(4, 4, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if l.Touch(4, Expired) {
+		t.Fatalf("expired 4 should not be touched")
+	}
+}
+
+func TestLRU_Range(t *testing.T) {
+	l, err := NewLRUCache(8, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Put(4, 4, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	var keys []interface{}
+	l.Range(func(k, v interface{}) bool {
+		if k != v {
+			t.Fatalf("bad value for %v: %v", k, v)
+		}
+		keys = append(keys, k)
+		return true
+	})
+	if len(keys) != 4 {
+		t.Fatalf("expired entry should be skipped: %v", keys)
+	}
+	for i, k := range keys {
+		if k != i {
+			t.Fatalf("should range from oldest to newest: %v", keys)
+		}
+	}
+
+	n := 0
+	l.Range(func(k, v interface{}) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Fatalf("Range should stop early: %v", n)
+	}
+}
+
+// Test that Get hits pending under the read lock keep the LRU order
+func TestLRU_GetPromotion(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Get(1)
+	l.Get(0)
+	keys := l.Keys()
+	want := []interface{}{2, 3, 1, 0}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("bad keys order: %v", keys)
+		}
+	}
+	l.Get(2)
+	l.Put(4, 4, Expired)
+	if l.Contains(3) || !l.Contains(2) {
+		t.Fatalf("3 should be evicted")
+	}
+
+	// more hits than the promotion buffer
+	for i := 0; i < maxPromotions*2+1; i++ {
+		l.Get(i % 2)
+	}
+	l.Remove(0)
+	l.Put(5, 5, Expired)
+	keys = l.Keys()
+	want = []interface{}{2, 4, 1, 5}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("bad keys order: %v", keys)
+		}
+	}
+}
+
+func BenchmarkLRU_GetParallel(b *testing.B) {
+	l, err := NewLRUCache(8192, Expired, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8192; i++ {
+		l.Put(i, i, Expired)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			l.Get(i % 8192)
+			i++
+		}
+	})
+}
+
+func benchmarkFill(b *testing.B, opts ...Option) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		l, err := NewLRUCache(8192, Expired, nil, opts...)
+		if err != nil {
+			b.Fatalf("err: %v", err)
+		}
+		for i := 0; i < 8192; i++ {
+			l.Put(i, i, Expired)
+		}
+	}
+}
+
+// benchmarkGetString looks up string keys with get, which must not allocate on a hit
+func benchmarkGetString(b *testing.B, get func(l *LruCache, key string)) {
+	l, err := NewLRUCache(1024, Expired, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strings.Repeat("k", i%16) + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		l.Put(keys[i], i, Expired)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		get(l, keys[n%len(keys)])
+	}
+}
+
+func BenchmarkLRU_GetStringKey(b *testing.B) {
+	benchmarkGetString(b, func(l *LruCache, key string) { l.Get(key) })
+}
+
+func BenchmarkLRU_GetString(b *testing.B) {
+	benchmarkGetString(b, func(l *LruCache, key string) { l.GetString(key) })
+}
+
+// benchmarkGet hits the entries of a cache stored with ttl
+func benchmarkGet(b *testing.B, ttl time.Duration, opts ...Option) {
+	l, err := NewLRUCache(1024, 0, nil, opts...)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1024; i++ {
+		l.Put(i, i, ttl)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		l.Get(n % 1024)
+	}
+}
+
+func BenchmarkLRU_GetTTL(b *testing.B) {
+	benchmarkGet(b, time.Hour)
+}
+
+func BenchmarkLRU_GetNoTTL(b *testing.B) {
+	benchmarkGet(b, 0)
+}
+
+func BenchmarkLRU_GetWithoutExpiry(b *testing.B) {
+	benchmarkGet(b, time.Hour, WithoutExpiry())
+}
+
+func BenchmarkLRU_Fill(b *testing.B) {
+	benchmarkFill(b)
+}
+
+func BenchmarkLRU_FillPreallocated(b *testing.B) {
+	benchmarkFill(b, WithPreallocation(8192))
+}
+
+func TestLRU_EvictBatch(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(10, Expired, onEvicted, WithEvictBatch(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		l.Put(i, i, Expired)
+	}
+	if !l.Put(10, 10, Expired) || l.Len() != 7 || evictCounter != 4 {
+		t.Fatalf("a batch should be evicted: len %v, evict count %v", l.Len(), evictCounter)
+	}
+	if keys := l.Keys(); keys[0] != 4 {
+		t.Fatalf("the oldest should be evicted: %v", keys)
+	}
+	for i := 11; i < 14; i++ {
+		if l.Put(i, i, Expired) {
+			t.Fatalf("%d should fit in the headroom", i)
+		}
+	}
+	if !l.Put(14, 14, Expired) || l.Len() != 7 || evictCounter != 8 {
+		t.Fatalf("a batch should be evicted: len %v, evict count %v", l.Len(), evictCounter)
+	}
+
+	// the inserted entry is kept
+	small, _ := NewLRUCache(2, Expired, nil, WithEvictBatch(4))
+	small.Put(1, 1, Expired)
+	small.Put(2, 2, Expired)
+	small.Put(3, 3, Expired)
+	if keys := small.Keys(); len(keys) != 1 || keys[0] != 3 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLRU_Preallocation(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil, WithPreallocation(4))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Put(i, i, Expired)
+	}
+	if keys := l.Keys(); len(keys) != 4 || keys[0] != 4 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLRU_PutWithCallback(t *testing.T) {
+	var calls []string
+	onEvicted := func(k interface{}, v interface{}) {
+		calls = append(calls, "cache")
+	}
+	l, err := NewLRUCache(1, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	closed := 0
+	closer := func(k interface{}, v interface{}) {
+		calls = append(calls, "entry")
+		closed++
+	}
+	l.PutWithCallback(1, 1, Expired, closer)
+	l.Put(2, 2, Expired)
+	if closed != 1 || len(calls) != 2 || calls[0] != "entry" || calls[1] != "cache" {
+		t.Fatalf("bad callbacks: %v", calls)
+	}
+
+	// the entry callback fires when its value is replaced, and is dropped with it
+	l.PutWithCallback(2, 3, Expired, closer)
+	l.Put(2, 4, Expired)
+	if closed != 2 {
+		t.Fatalf("entry callback should fire on replace: %v", closed)
+	}
+	l.Remove(2)
+	if closed != 2 {
+		t.Fatalf("entry callback should be dropped with its value: %v", closed)
+	}
+}
+
+func TestLRU_SlidingExpiration(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetSlidingExpiration(true)
+
+	l.Put(1, 1, 40*time.Millisecond)
+	l.Put(2, 2, 40*time.Millisecond)
+	l.Put(3, 3, 0)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := l.Get(1); !ok {
+			t.Fatalf("accessed 1 should not expire")
+		}
+	}
+	if l.Contains(2) {
+		t.Fatalf("idle 2 should be expired")
+	}
+	if _, ok := l.Get(3); !ok {
+		t.Fatalf("3 should never expire")
+	}
+	if d, _ := l.TTL(3); d != NoExpiration {
+		t.Fatalf("3 should never expire: %v", d)
+	}
+
+	l.SetSlidingExpiration(false)
+	time.Sleep(20 * time.Millisecond)
+	l.Get(1)
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should expire at a fixed deadline")
+	}
+}
+
+func TestLRU_MGet(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Put(3, 3, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	found := l.MGet([]interface{}{1, 0, 3, 5})
+	if len(found) != 2 || found[0] != 0 || found[1] != 1 {
+		t.Fatalf("bad found: %v", found)
+	}
+	keys := l.Keys()
+	if len(keys) != 3 || keys[0] != 2 || keys[1] != 1 || keys[2] != 0 {
+		t.Fatalf("hits should be moved to front in order: %v", keys)
+	}
+	if s := l.Stats(); s.Hits != 2 || s.Misses != 2 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+}
+
+func TestLRU_TryGet(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	if v, ok, acquired := l.TryGet(1); !acquired || !ok || v != 1 {
+		t.Fatalf("bad TryGet: %v %v %v", v, ok, acquired)
+	}
+	if _, ok, acquired := l.TryGet(3); !acquired || ok {
+		t.Fatalf("3 should miss")
+	}
+	// the hit moved 1 to the front
+	l.Put(3, 3, Expired)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+
+	l.lock.Lock()
+	if _, ok, acquired := l.TryGet(1); acquired || ok {
+		t.Fatalf("TryGet should not wait for the lock")
+	}
+	l.lock.Unlock()
+	if s := l.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+
+	l.SetSlidingExpiration(true)
+	if v, ok, acquired := l.TryGet(3); !acquired || !ok || v != 3 {
+		t.Fatalf("bad sliding TryGet: %v %v %v", v, ok, acquired)
+	}
+	l.lock.RLock()
+	if _, _, acquired := l.TryGet(3); acquired {
+		t.Fatalf("sliding TryGet should not wait for the write lock")
+	}
+	l.lock.RUnlock()
+}
+
+func TestLRU_TryGetFullPromotions(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	for i := 0; i < maxPromotions*2; i++ {
+		l.TryGet(1)
+	}
+	if n := atomic.LoadInt32(&l.npromotions); n != maxPromotions {
+		t.Fatalf("a full buffer should not grow the counter: %v", n)
+	}
+
+	// the counter is left alone rather than wrapping once the buffer is full
+	atomic.StoreInt32(&l.npromotions, math.MaxInt32-1)
+	for i := 0; i < 4; i++ {
+		if v, ok, acquired := l.TryGet(1); !acquired || !ok || v != 1 {
+			t.Fatalf("bad TryGet: %v %v %v", v, ok, acquired)
+		}
+	}
+	l.Put(3, 3, Expired)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+}
+
+func TestLRU_GetString(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put("a", 1, Expired)
+	l.Put("b", 2, Expired)
+	if v, ok := l.GetString("a"); !ok || v != 1 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if _, ok := l.GetString("c"); ok {
+		t.Fatalf("c should miss")
+	}
+	// the hit moved a to the front, the miss evicted b
+	l.Put("c", 3, Expired)
+	if !l.Contains("a") || l.Contains("b") {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+	if s := l.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Fatalf("bad stats: %+v", s)
+	}
+	if n := testing.AllocsPerRun(100, func() { l.GetString("a") }); n != 0 {
+		t.Fatalf("a hit should not allocate: %v", n)
+	}
+}
+
+func TestLRU_GetMulti(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Put(2, 2, 10*time.Millisecond)
+	l.PutMiss(3, Expired)
+	time.Sleep(20 * time.Millisecond)
+
+	found, missing := l.GetMulti([]interface{}{5, 1, 2, 0, 3, 4})
+	if len(found) != 2 || found[0] != 0 || found[1] != 1 {
+		t.Fatalf("bad found: %v", found)
+	}
+	if len(missing) != 3 || missing[0] != 5 || missing[1] != 2 || missing[2] != 4 {
+		t.Fatalf("bad missing: %v", missing)
+	}
+	if keys := l.Keys(); len(keys) != 2 || keys[0] != 1 || keys[1] != 0 {
+		t.Fatalf("hits should be moved to front in order: %v", keys)
+	}
+}
+
+func TestLRU_MPut(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(4, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put("a", 0, Expired)
+	items := make(map[interface{}]interface{})
+	for i := 0; i < 6; i++ {
+		items[i] = i
+	}
+	if evicted := l.MPut(items, Expired); evicted != 3 || evictCounter != 3 {
+		t.Fatalf("bad evicted: %v, evict count: %v", evicted, evictCounter)
+	}
+	if l.Len() != 4 || l.Contains("a") {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	for _, k := range l.Keys() {
+		if v, ok := l.Peek(k); !ok || v != k {
+			t.Fatalf("bad value for %v: %v", k, v)
+		}
+	}
+}
+
+func TestLRU_Invalidate(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}, reason EvictReason) {
+		if reason == ReasonExpired {
+			evicted = append(evicted, k)
+		}
+	}
+	l, err := NewLRUCacheWithEvictReason(4, 0, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, 0)
+	}
+	l.Invalidate()
+	if l.Len() != 4 || l.LenLive() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("the entries should be stale but kept: %v %v", l.Len(), l.LenLive())
+	}
+	if _, ok := l.Get(0); ok || l.Contains(1) {
+		t.Fatalf("stale entries should miss")
+	}
+	if len(evicted) != 1 || evicted[0] != 0 || l.Len() != 3 {
+		t.Fatalf("the stale entry should be removed on lookup: %v", evicted)
+	}
+	if e := l.Entries(); len(e) != 3 || !e[0].Expired {
+		t.Fatalf("stale entries should be reported expired: %+v", e)
+	}
+
+	// the entries stored since are live and the stale ones make room first
+	l.Put(1, "new", 0)
+	l.Put(4, 4, 0)
+	l.Put(5, 5, 0)
+	if v, ok := l.Get(1); !ok || v != "new" {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if keys := l.Keys(); len(keys) != 3 || l.Len() != 4 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if l.FlushExpired() != 1 || l.Len() != 3 {
+		t.Fatalf("the last stale entry should be flushed")
+	}
+	if len(evicted) != 3 || evicted[1] != 2 || evicted[2] != 3 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}
+
+func TestLRU_Purge(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(4, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.PutWithCallback(4, 4, Expired, onEvicted)
+	l.Get(2)
+	l.Purge()
+	if l.Len() != 0 || evictCounter != 1 {
+		t.Fatalf("bad len: %v, evict count: %v", l.Len(), evictCounter)
+	}
+	l.Put(1, 1, Expired)
+	if v, ok := l.Get(1); !ok || v != 1 || len(l.Keys()) != 1 {
+		t.Fatalf("cache should be usable after Purge")
+	}
+}
+
+func TestLRU_Drain(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, 0, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, "two", 0)
+	l.Put(3, 3, time.Second)
+	l.PutMiss(4, time.Minute)
+	l.Pin(1)
+	clock.Advance(2 * time.Second)
+
+	items := l.Drain()
+	if len(items) != 2 || items[1] != 1 || items[2] != "two" {
+		t.Fatalf("bad items: %v", items)
+	}
+	if l.Len() != 0 || l.PinnedCount() != 0 || evictCounter != 0 {
+		t.Fatalf("bad len: %v, pinned: %v, evict count: %v", l.Len(), l.PinnedCount(), evictCounter)
+	}
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should be drained")
+	}
+	if items := l.Drain(); len(items) != 0 {
+		t.Fatalf("bad items: %v", items)
+	}
+}
+
+func TestLRU_RemoveOldest(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(4, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, ok := l.RemoveOldest(); ok {
+		t.Fatalf("empty cache should have no oldest")
+	}
+	l.Put(0, 0, 10*time.Millisecond)
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Get(1)
+	time.Sleep(20 * time.Millisecond)
+
+	if k, v, ok := l.RemoveOldest(); !ok || k != 2 || v != 2 {
+		t.Fatalf("bad oldest: %v, %v", k, v)
+	}
+	if k, _, _ := l.RemoveOldest(); k != 1 {
+		t.Fatalf("bad oldest: %v", k)
+	}
+	if l.Len() != 0 || evictCounter != 3 {
+		t.Fatalf("bad len: %v, evict count: %v", l.Len(), evictCounter)
+	}
+}
+
+// Test that Keys only returns the live keys
+func TestLRU_KeysExpired(t *testing.T) {
+	l, err := NewLRUCache(8, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		if i%2 == 0 {
+			l.Put(i, i, 10*time.Millisecond)
+		} else {
+			l.Put(i, i, Expired)
+		}
+	}
+	l.Put(6, 6, 0)
+	l.PutMiss(7, Expired)
+	time.Sleep(20 * time.Millisecond)
+
+	keys := l.Keys()
+	want := []interface{}{1, 3, 5, 6}
+	if len(keys) != len(want) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	for i, k := range keys {
+		if k != want[i] {
+			t.Fatalf("bad keys: %v", keys)
+		}
+		if _, ok := l.Peek(k); !ok {
+			t.Fatalf("%v should be live", k)
+		}
+	}
+}
+
+func TestLRU_SetOnEvict(t *testing.T) {
+	l, err := NewLRUCache(1, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	evicted := make(map[interface{}]bool)
+	l.SetOnEvict(func(k interface{}, v interface{}) {
+		evicted[k] = true
+	})
+	l.Put(3, 3, Expired)
+	if len(evicted) != 1 || !evicted[2] {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	l.SetOnEvict(nil)
+	l.Put(4, 4, Expired)
+	if len(evicted) != 1 {
+		t.Fatalf("callback should be removed: %v", evicted)
+	}
+}
+
+func TestLRU_Cap(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if l.Cap() != 4 {
+		t.Fatalf("bad cap: %v", l.Cap())
+	}
+	l.Resize(8)
+	if l.Cap() != 8 {
+		t.Fatalf("bad cap: %v", l.Cap())
+	}
+}
+
+func TestLRU_GetWithTTL(t *testing.T) {
+	l, err := NewLRUCache(2, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 0)
+	if v, d, ok := l.GetWithTTL(1); !ok || v != 1 || d <= 0 || d > Expired {
+		t.Fatalf("bad ttl for 1: %v, %v, %v", v, d, ok)
+	}
+	if v, d, ok := l.GetWithTTL(2); !ok || v != 2 || d != NoExpiration {
+		t.Fatalf("2 should never expire: %v, %v, %v", v, d, ok)
+	}
+	if _, _, ok := l.GetWithTTL(3); ok {
+		t.Fatalf("3 should be missing")
+	}
+	// GetWithTTL moves to front
+	l.GetWithTTL(1)
+	l.Put(3, 3, 0)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("GetWithTTL should have updated recent-ness of 1")
+	}
+}
+
+func TestLRU_DisableRecencyUpdates(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.DisableRecencyUpdates(true)
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Get(1)
+	l.Put(1, 10, Expired)
+	l.Touch(1, Expired)
+	l.Put(3, 3, Expired)
+	if l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("1 should be evicted first in insertion order")
+	}
+
+	l.DisableRecencyUpdates(false)
+	l.Get(2)
+	l.Put(4, 4, Expired)
+	if !l.Contains(2) || l.Contains(3) {
+		t.Fatalf("3 should be evicted as least recently used")
+	}
+}
+
+func TestLRU_LenLive(t *testing.T) {
+	l, err := NewLRUCache(32, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		l.Put(i, i, 10*time.Millisecond)
+		l.Put(i+8, i, Expired)
+	}
+	l.PutMiss(16, Expired)
+	time.Sleep(20 * time.Millisecond)
+
+	if l.Len() != 17 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if l.LenLive() != 8 {
+		t.Fatalf("bad live len: %v", l.LenLive())
+	}
+}
+
+func TestLRU_Unbounded(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(Unbounded, time.Second, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if l.Put(i, i, 0) {
+			t.Fatalf("%d should not evict", i)
+		}
+	}
+	l.Get(0)
+	if keys := l.Keys(); l.Len() != 1000 || keys[0] != 1 || keys[999] != 0 {
+		t.Fatalf("bad keys: %v", keys[:2])
+	}
+	clock.Advance(2 * time.Second)
+	if l.FlushExpired() != 1000 || evictCounter != 1000 {
+		t.Fatalf("the entries should expire")
+	}
+
+	if _, err := l.Resize(2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, 0)
+	l.Put(3, 3, 0)
+	if l.Len() != 2 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := l.Resize(Unbounded); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(4, 4, 0)
+	if l.Len() != 3 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := NewLRUCache(-2, 0, nil); err == nil {
+		t.Fatalf("-2 should be rejected")
+	}
+}
+
+func TestLRU_Merge(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	other, err := NewLRUCache(8, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, "l1", 0)
+	l.Put(2, "l2", 0)
+	other.Put(2, "o2", time.Minute)
+	other.Put(3, "o3", time.Second)
+	other.Put(4, "o4", 0)
+	other.PutMiss(5, time.Minute)
+	other.Put(6, "o6", time.Minute)
+	other.Put(7, "o7", time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+
+	l.Merge(other, false)
+	if v, _ := l.Peek(2); v != "l2" {
+		t.Fatalf("2 should not be overwritten: %v", v)
+	}
+	if l.Contains(5) || l.Contains(7) {
+		t.Fatalf("negative and expired entries should not be merged: %v", l.Keys())
+	}
+	if keys := l.Keys(); len(keys) != 4 || keys[0] != 2 || keys[1] != 3 || keys[2] != 4 || keys[3] != 6 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if len(evicted) != 1 || evicted[0] != 1 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if ttl, _ := l.TTL(3); ttl != time.Second-10*time.Millisecond {
+		t.Fatalf("3 should keep its remaining ttl: %v", ttl)
+	}
+	if ttl, _ := l.TTL(4); ttl != NoExpiration {
+		t.Fatalf("4 should never expire: %v", ttl)
+	}
+
+	l.Merge(other, true)
+	if v, _ := l.Peek(2); v != "o2" {
+		t.Fatalf("2 should be overwritten: %v", v)
+	}
+	l.Merge(l, true)
+	if l.Len() != 4 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestLRU_Snapshot(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(3, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 10*time.Millisecond)
+	l.Put(3, 3, Expired)
+	l.Get(1)
+	time.Sleep(20 * time.Millisecond)
+
+	s := l.Snapshot()
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != 3 || keys[1] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if a, b := l.Entries()[2].ExpiresAt, s.Entries()[1].ExpiresAt; !a.Equal(*b) {
+		t.Fatalf("deadline should be kept: %v %v", a, b)
+	}
+
+	s.Put(4, 4, Expired)
+	s.Put(5, 5, Expired)
+	s.Remove(1)
+	if evictCounter != 0 {
+		t.Fatalf("snapshot should not fire the callbacks: %v", evictCounter)
+	}
+	if s.Len() != 2 || s.Cap() != 3 {
+		t.Fatalf("bad snapshot len: %v", s.Len())
+	}
+	if v, ok := l.Get(3); !ok || v != 3 {
+		t.Fatalf("original should not change")
+	}
+	if l.Contains(4) || l.Len() != 3 {
+		t.Fatalf("original should not change")
+	}
+}
+
+func TestLRU_EvictExpiredFirst(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}, reason EvictReason) {
+		evicted = append(evicted, k, reason)
+	}
+	l, err := NewLRUCacheWithEvictReason(3, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 10*time.Millisecond)
+	l.Put(3, 3, Expired)
+	time.Sleep(20 * time.Millisecond)
+
+	// 1 is the oldest but still live, the expired 2 makes room instead
+	l.Put(4, 4, Expired)
+	if len(evicted) != 2 || evicted[0] != 2 || evicted[1] != ReasonExpired {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if !l.Contains(1) {
+		t.Fatalf("1 should not be evicted")
+	}
+
+	l.Put(5, 5, Expired)
+	if len(evicted) != 4 || evicted[2] != 1 || evicted[3] != ReasonCapacity {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}
+
+func TestLRU_NilValue(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, nil, Expired)
+
+	if v, ok := l.Get(1); !ok || v != nil {
+		t.Fatalf("nil should be found: %v, %v", v, ok)
+	}
+	if v, ok := l.Peek(1); !ok || v != nil {
+		t.Fatalf("nil should be found: %v, %v", v, ok)
+	}
+	if found := l.MGet([]interface{}{1, 2}); len(found) != 1 {
+		t.Fatalf("nil should be found: %v", found)
+	}
+	if v, err := l.Lookup(1); err != nil || v != nil {
+		t.Fatalf("nil should be found: %v, %v", v, err)
+	}
+	if v, loaded := l.PutIfAbsent(1, 2, Expired); !loaded || v != nil {
+		t.Fatalf("nil should be loaded: %v, %v", v, loaded)
+	}
+	v, err := l.GetOrLoad(1, func() (interface{}, error) {
+		t.Fatalf("loader should not be called")
+		return nil, nil
+	})
+	if err != nil || v != nil {
+		t.Fatalf("nil should be found: %v, %v", v, err)
+	}
+
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return nil, nil
+	}
+	l.GetOrLoad(2, loader)
+	l.GetOrLoad(2, loader)
+	if calls != 1 {
+		t.Fatalf("loaded nil should be cached: %v", calls)
+	}
+	if v, ok := l.Get(3); ok || v != nil {
+		t.Fatalf("3 should be missing")
+	}
+}
+
+func TestLRU_KeysCopy(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	keys := l.Keys()
+	l.Remove(1)
+	l.Put(3, 3, Expired)
+	if len(keys) != 2 || keys[0] != 1 || keys[1] != 2 {
+		t.Fatalf("keys should not follow the later writes: %v", keys)
+	}
+	keys[0] = 4
+	if l.Contains(4) || l.Keys()[0] != 2 {
+		t.Fatalf("keys should be a copy: %v", l.Keys())
+	}
+}
+
+func TestLRU_RemoveFunc(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	l, err := NewLRUCache(8, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, k := range []string{"a/1", "b/1", "a/2", "b/2", "a/3"} {
+		l.Put(k, k, Expired)
+	}
+	l.PutMiss("a/4", Expired)
+
+	n := l.RemoveFunc(func(key, value interface{}) bool {
+		return strings.HasPrefix(key.(string), "a/")
+	})
+	if n != 4 {
+		t.Fatalf("bad removed count: %v", n)
+	}
+	if len(evicted) != 3 || evicted[0] != "a/1" || evicted[1] != "a/2" || evicted[2] != "a/3" {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if keys := l.Keys(); len(keys) != 2 || keys[0] != "b/1" || keys[1] != "b/2" || l.Len() != 2 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLRU_RecentOldestKeys(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Put(0, 0, 10*time.Millisecond)
+	l.Put(4, 4, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	l.Get(1)
+
+	if keys := l.RecentKeys(2); len(keys) != 2 || keys[0] != 1 || keys[1] != 3 {
+		t.Fatalf("bad recent keys: %v", keys)
+	}
+	if keys := l.OldestKeys(2); len(keys) != 2 || keys[0] != 2 || keys[1] != 3 {
+		t.Fatalf("bad oldest keys: %v", keys)
+	}
+	if keys := l.RecentKeys(100); len(keys) != 3 || keys[2] != 2 {
+		t.Fatalf("bad recent keys: %v", keys)
+	}
+	if keys := l.OldestKeys(0); len(keys) != 0 {
+		t.Fatalf("bad oldest keys: %v", keys)
+	}
+	// listing the keys does not change the order
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 2 || keys[2] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
+
+func TestLRU_KeysNewestFirst(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys := l.KeysNewestFirst(); len(keys) != 0 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	for i := 0; i < 5; i++ {
+		l.Put(i, i, Expired)
+	}
+	l.Put(5, 5, 10*time.Millisecond)
+	l.PutMiss(6, Expired)
+	l.Get(1)
+	clock.Advance(20 * time.Millisecond)
+
+	keys, oldest := l.KeysNewestFirst(), l.Keys()
+	want := []int{1, 4, 3, 2, 0}
+	if len(keys) != len(want) || len(oldest) != len(want) {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	for i, k := range want {
+		if keys[i] != k || oldest[len(oldest)-1-i] != k {
+			t.Fatalf("bad keys: %v, oldest first: %v", keys, oldest)
+		}
+	}
+}
+
+func TestLRU_PutWithMeta(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.PutWithMeta(1, 1, "db", Expired)
+	l.Put(2, 2, Expired)
+
+	if v, meta, ok := l.GetWithMeta(1); !ok || v != 1 || meta != "db" {
+		t.Fatalf("bad value: %v, %v", v, meta)
+	}
+	if v, meta, ok := l.GetWithMeta(2); !ok || v != 2 || meta != nil {
+		t.Fatalf("bad value: %v, %v", v, meta)
+	}
+	l.Update(1, 10)
+	if v, meta, _ := l.GetWithMeta(1); v != 10 || meta != "db" {
+		t.Fatalf("update should keep the meta: %v, %v", v, meta)
+	}
+
+	// the hit on 1 made 2 the oldest
+	l.Put(3, 3, Expired)
+	if _, _, ok := l.GetWithMeta(2); ok {
+		t.Fatalf("2 should be evicted")
+	}
+	l.Put(1, 1, Expired)
+	if _, meta, _ := l.GetWithMeta(1); meta != nil {
+		t.Fatalf("put should drop the meta: %v", meta)
+	}
+}
+
+func TestLRU_ClearKeysConcurrent(t *testing.T) {
+	l, err := NewLRUCache(64, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(3)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Put(g*1000+i, i, Expired)
+				l.Get(g*1000 + i - 1)
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				l.Clear()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if keys := l.Keys(); len(keys) > 64 {
+					t.Errorf("bad keys len: %v", len(keys))
+				}
+				l.Range(func(key, value interface{}) bool { return true })
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLRU_SameKeyConcurrent(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				key := i % 6
+				switch i % 5 {
+				case 0:
+					l.Remove(key)
+				case 1:
+					l.Update(key, g)
+				default:
+					l.Put(key, g, Expired)
+				}
+				l.Get((i + g) % 6)
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if n := l.Len(); n > 4 {
+					t.Errorf("bad len: %v", n)
+				}
+				checkConsistent(t, l)
+			}
+		}()
+	}
+	wg.Wait()
+	checkConsistent(t, l)
+	for i := 0; i < 6; i++ {
+		l.Put(i, i, Expired)
+	}
+	checkConsistent(t, l)
+	if l.Len() != 4 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+// checkConsistent checks that the list and the map of l hold the same unique entries
+func checkConsistent(t *testing.T, l *LruCache) {
+	t.Helper()
+	keys := l.Keys()
+	seen := make(map[interface{}]bool, len(keys))
+	for _, k := range keys {
+		if seen[k] {
+			t.Errorf("duplicate key %v: %v", k, keys)
+		}
+		seen[k] = true
+	}
+	l.writeLock()
+	defer l.writeUnlock()
+	if l.evictList.Len() != len(l.cache) || l.weight != len(l.cache) {
+		t.Errorf("list len %d, map len %d, weight %d", l.evictList.Len(), len(l.cache), l.weight)
+	}
+	for e := l.evictList.Front(); e != nil; e = e.Next() {
+		if l.cache[e.Value.(*entry).key] != e {
+			t.Errorf("element of %v not in the map", e.Value.(*entry).key)
+		}
+	}
+}
+
+func TestLRU_RemoveOlderThan(t *testing.T) {
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(8, 0, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, time.Hour)
+	l.Put(3, 3, 0)
+	l.PutMiss(4, time.Hour)
+	clock.Advance(time.Minute)
+	l.Put(3, "new", 0)
+	l.Touch(2, time.Hour)
+	l.Put(5, 5, 0)
+	clock.Advance(time.Second)
+
+	if n := l.RemoveOlderThan(30 * time.Second); n != 3 || l.Len() != 2 {
+		t.Fatalf("bad removed count: %v", n)
+	}
+	if !l.Contains(3) || !l.Contains(5) {
+		t.Fatalf("bad keys: %v", l.Keys())
+	}
+	if len(evicted) != 2 || evicted[0] != 1 || evicted[1] != 2 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+	if n := l.RemoveOlderThan(0); n != 2 || l.Len() != 0 {
+		t.Fatalf("bad removed count: %v", n)
+	}
+}
+
+func TestLRU_RemovePrefix(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(8, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put("user:1:profile", 1, Expired)
+	l.Put("user:1:settings", 2, Expired)
+	l.Put("user:2:profile", 3, Expired)
+	l.Put(1, 4, Expired)
+
+	if n := l.RemovePrefix("user:1:"); n != 2 || evictCounter != 2 {
+		t.Fatalf("bad removed count: %v", n)
+	}
+	if keys := l.Keys(); len(keys) != 2 || keys[0] != "user:2:profile" || keys[1] != 1 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	if n := l.RemovePrefix(""); n != 1 || !l.Contains(1) {
+		t.Fatalf("only string keys should be removed: %v", n)
+	}
+}
+
+func TestLRU_GetAndRemove(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, Expired, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+
+	if v, ok := l.GetAndRemove(1); !ok || v != 1 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if _, ok := l.GetAndRemove(1); ok || l.Contains(1) {
+		t.Fatalf("1 should be removed")
+	}
+	if evictCounter != 0 {
+		t.Fatalf("should not fire onEvict: %v", evictCounter)
+	}
+	if _, ok := l.GetAndRemove(2); ok || l.Len() != 0 || evictCounter != 1 {
+		t.Fatalf("expired 2 should be removed")
+	}
+}
+
+func TestLRU_GetAndRemoveConcurrent(t *testing.T) {
+	l, err := NewLRUCache(128, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		l.Put(i, i, Expired)
+	}
+	var popped int32
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if _, ok := l.GetAndRemove(i); ok {
+					atomic.AddInt32(&popped, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if popped != 100 {
+		t.Fatalf("each item should be popped once: %v", popped)
+	}
+}
+
+func TestLRU_SetOnExpire(t *testing.T) {
+	var evicted, expired []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		evicted = append(evicted, k)
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(3, time.Second, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetOnExpire(func(k interface{}, v interface{}) {
+		expired = append(expired, k)
+	})
+	for i := 0; i < 3; i++ {
+		l.Put(i, i, 0)
+	}
+	clock.Advance(2 * time.Second)
+	l.Get(0)
+	l.Put(3, 3, time.Minute)
+	l.Put(4, 4, time.Minute)
+	l.FlushExpired()
+	l.Put(5, 5, time.Minute)
+	l.Put(6, 6, time.Minute)
+	l.Remove(5)
+	if len(expired) != 3 || expired[0] != 0 || expired[1] != 1 || expired[2] != 2 {
+		t.Fatalf("bad expired: %v", expired)
+	}
+	if len(evicted) != 2 || evicted[0] != 3 || evicted[1] != 5 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+
+	// without onExpire the expired entries go to onEvict again
+	l.SetOnExpire(nil)
+	clock.Advance(2 * time.Minute)
+	l.Get(4)
+	if len(expired) != 3 || len(evicted) != 3 || evicted[2] != 4 {
+		t.Fatalf("bad evicted: %v, expired: %v", evicted, expired)
+	}
+}
+
+func TestLRU_SetOnAdd(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var added []interface{}
+	l.SetOnAdd(func(k, v interface{}) {
+		added = append(added, k)
+	})
+
+	l.Put(1, 1, Expired)
+	l.Put(1, 10, Expired)
+	l.PutIfAbsent(1, 100, Expired)
+	l.PutIfAbsent(2, 2, Expired)
+	l.Update(2, 20)
+	l.PutMiss(3, Expired)
+	l.Put(4, 4, Expired)
+	if len(added) != 3 || added[0] != 1 || added[1] != 2 || added[2] != 4 {
+		t.Fatalf("bad added: %v", added)
+	}
+
+	// the expired keys not removed yet are replaced, not added
+	l.Put(1, 1, time.Second)
+	l.Put(4, 4, time.Second)
+	n := len(added)
+	clock.Advance(2 * time.Second)
+	if _, loaded := l.GetOrPut(1, 10, Expired); loaded {
+		t.Fatalf("expired 1 should be stored")
+	}
+	if _, loaded := l.PutIfAbsent(4, 40, Expired); loaded {
+		t.Fatalf("expired 4 should be stored")
+	}
+	l.Put(1, 1, time.Second)
+	clock.Advance(2 * time.Second)
+	if l.ContainsOrPut(1, 100, Expired) {
+		t.Fatalf("expired 1 should be stored")
+	}
+	if len(added) != n {
+		t.Fatalf("bad added: %v", added)
+	}
+	if v, _ := l.Peek(1); v != 100 {
+		t.Fatalf("bad value for 1: %v", v)
+	}
+}
+
+func TestLRU_AsyncEvict(t *testing.T) {
+	var l *LruCache
+	var evicted []interface{}
+	onEvicted := func(k interface{}, v interface{}) {
+		// calling the cache would deadlock under the lock
+		if _, ok := l.Get(k); ok {
+			t.Errorf("%v should be gone", k)
+		}
+		l.Len()
+		evicted = append(evicted, k)
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, Expired, onEvicted, WithAsyncEvict(true), WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Put(1, 1, Expired)
+		l.Put(2, 2, Expired)
+		l.Put(3, 3, Expired)
+		l.Remove(2)
+		l.Put(4, 4, 10*time.Millisecond)
+		clock.Advance(20 * time.Millisecond)
+		l.Get(4)
+		l.Clear()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("deadlock in onEvict")
+	}
+	if len(evicted) != 4 || evicted[0] != 1 || evicted[1] != 2 || evicted[2] != 4 || evicted[3] != 3 {
+		t.Fatalf("bad evicted: %v", evicted)
+	}
+}
+
+func TestLRU_ReplaceIf(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, []int{2}, Expired)
+
+	if l.ReplaceIf(1, 2, 3, Expired) {
+		t.Fatalf("should not replace a different value")
+	}
+	if !l.ReplaceIf(1, 1, 3, Expired) {
+		t.Fatalf("should replace the matching value")
+	}
+	if v, _ := l.Get(1); v != 3 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if l.ReplaceIf(1, int64(3), 4, Expired) {
+		t.Fatalf("values of different types should not match")
+	}
+	if l.ReplaceIf(2, []int{2}, 4, Expired) {
+		t.Fatalf("uncomparable values should not match")
+	}
+	if l.ReplaceIf(5, nil, 5, Expired) || l.Contains(5) {
+		t.Fatalf("missing key should not be added")
+	}
+
+	l.Put(3, 3, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if l.ReplaceIf(3, 3, 4, Expired) {
+		t.Fatalf("expired key should not be replaced")
+	}
+}
+
+func TestLRU_EvictionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy    EvictionPolicy
+		survivor  int
+		evictedBy int
+	}{
+		{LRU, 2, 1},
+		{MRU, 1, 2},
+	} {
+		l, err := NewLRUCache(3, Expired, nil, WithEvictionPolicy(tc.policy))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		l.Put(1, 1, Expired)
+		l.Put(2, 2, Expired)
+		l.Put(3, 3, Expired)
+		l.Get(3)
+		l.Get(2)
+
+		l.Put(4, 4, Expired)
+		if !l.Contains(4) || !l.Contains(3) || !l.Contains(tc.survivor) || l.Contains(tc.evictedBy) {
+			t.Fatalf("policy %v: bad keys: %v", tc.policy, l.Keys())
+		}
+	}
+}
+
+func TestLRU_FullPolicy(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(2, Expired, onEvicted, WithFullPolicy(PolicyEvict))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	if !l.Put(3, 3, Expired) || l.Contains(1) || evictCounter != 1 {
+		t.Fatalf("3 should evict 1: %v", l.Keys())
+	}
+
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	evictCounter = 0
+	l, err = NewLRUCache(2, Expired, onEvicted, WithFullPolicy(PolicyReject), WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Second)
+	l.Put(2, 2, Expired)
+	if l.Put(3, 3, Expired) || l.Contains(3) {
+		t.Fatalf("3 should be rejected: %v", l.Keys())
+	}
+	if err := l.PutE(3, 3, Expired); err != ErrCacheFull {
+		t.Fatalf("bad err: %v", err)
+	}
+	if l.Put(1, "one", time.Second) || evictCounter != 0 {
+		t.Fatalf("1 should be updated without evictions")
+	}
+	if v, _ := l.Get(1); v != "one" {
+		t.Fatalf("bad value: %v", v)
+	}
+
+	// expired entries still make room
+	clock.Advance(2 * time.Second)
+	if err := l.PutE(3, 3, Expired); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !l.Contains(3) || l.Contains(1) || evictCounter != 1 {
+		t.Fatalf("3 should replace the expired 1: %v", l.Keys())
+	}
+}
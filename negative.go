@@ -0,0 +1,55 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrCachedMiss is returned for a key remembered as missing by PutMiss
+	ErrCachedMiss = errors.New("lrucache: cached miss")
+	// ErrNotFound is returned for a key absent from the cache
+	ErrNotFound = errors.New("lrucache: not found")
+)
+
+// PutMiss remembers that key has no value for the specified maximum duration.
+// A negative entry takes a slot in the cache like any other entry, but Get, Peek and
+// Contains report it as missing and it never fires the eviction callbacks.
+func (c *LruCache) PutMiss(key interface{}, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.set(&entry{key: key, lifetime: c.lifetime(ttl), miss: true}) > 0
+}
+
+// Lookup gets a key's value from the cache like Get, telling a cached miss apart:
+// it returns ErrCachedMiss for a key stored by PutMiss and ErrNotFound for an absent key.
+// With a Loader set by WithLoader, an absent key is loaded and the error of the load is returned.
+func (c *LruCache) Lookup(key interface{}) (interface{}, error) {
+	value, err := c.lookup(key)
+	if err == ErrNotFound && c.loader != nil {
+		return c.loadThrough(context.Background(), key)
+	}
+	return value, err
+}
+
+// lookup is Lookup without the Loader
+func (c *LruCache) lookup(key interface{}) (interface{}, error) {
+	c.writeLock()
+	defer c.writeUnlock()
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+	if ent, ok := c.cache[key]; ok && ent.Value.(*entry).miss {
+		return nil, ErrCachedMiss
+	}
+	return nil, ErrNotFound
+}
+
+// isMiss returns true if key is a live negative entry
+func (c *LruCache) isMiss(key interface{}) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	ent, ok := c.cache[key]
+	return ok && ent.Value.(*entry).miss && !c.expired(ent.Value.(*entry))
+}
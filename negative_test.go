@@ -0,0 +1,71 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_PutMiss(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(2, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.PutMiss("missing", Expired)
+	if _, ok := l.Get("missing"); ok {
+		t.Fatalf("negative entry should miss")
+	}
+	if _, ok := l.Peek("missing"); ok || l.Contains("missing") {
+		t.Fatalf("negative entry should miss")
+	}
+	if _, err := l.Lookup("missing"); err != ErrCachedMiss {
+		t.Fatalf("bad err: %v", err)
+	}
+	if _, err := l.Lookup("absent"); err != ErrNotFound {
+		t.Fatalf("bad err: %v", err)
+	}
+	if _, err := l.GetOrLoad("missing", func() (interface{}, error) {
+		t.Fatalf("loader should not be called")
+		return nil, nil
+	}); err != ErrCachedMiss {
+		t.Fatalf("bad err: %v", err)
+	}
+
+	if _, ok := l.TTL("missing"); ok {
+		t.Fatalf("negative entry should have no ttl")
+	}
+	if l.Touch("missing", Expired) || l.ExtendTTL("missing", Expired) {
+		t.Fatalf("negative entry should not be touched nor extended")
+	}
+
+	l.Put("missing", 1, Expired)
+	if v, err := l.Lookup("missing"); err != nil || v != 1 {
+		t.Fatalf("Put should replace the negative entry: %v, %v", v, err)
+	}
+	l.PutMiss("missing", Expired)
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	if evictCounter != 0 {
+		t.Fatalf("negative entries should not fire onEvict: %v", evictCounter)
+	}
+}
+
+func TestLRU_PutMissExpired(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.PutMiss("missing", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	v, err := l.GetOrLoad("missing", func() (interface{}, error) {
+		return "found", nil
+	})
+	if err != nil || v != "found" {
+		t.Fatalf("expired negative entry should load: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,59 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// ErrLoaderPanic is returned by a load whose loader panicked
+var ErrLoaderPanic = errors.New("lrucache: loader panicked")
+
+// WithPanicHandler sets the function receiving the panics recovered from the callbacks
+// and loaders given to the cache: the eviction, expiry, add and fill threshold callbacks,
+// the entry callbacks of PutWithCallback, the Loader, the loaders of GetOrLoad and the
+// reload of WithRefreshAhead. A panicking callback doesn't stop the operation calling it
+// and the other callbacks still fire, a panicking loader fails its load with ErrLoaderPanic.
+// The panics are logged with their stack trace by default.
+func WithPanicHandler(handler func(recovered interface{})) Option {
+	return func(c *LruCache) {
+		c.panicHandler = handler
+	}
+}
+
+// onPanic returns the function receiving the recovered panics
+func (c *LruCache) onPanic() func(interface{}) {
+	if c.panicHandler != nil {
+		return c.panicHandler
+	}
+	return logPanic
+}
+
+// logPanic logs a recovered panic along with the stack trace of the callback
+func logPanic(recovered interface{}) {
+	log.Printf("lrucache: recovered a panic from a callback: %v\n%s", recovered, debug.Stack())
+}
+
+// protect calls f, passing a panic of f to onPanic
+func protect(onPanic func(interface{}), f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			onPanic(r)
+		}
+	}()
+	f()
+}
+
+// callLoader calls loader, turning a panic into ErrLoaderPanic after passing it to the panic handler
+func (c *LruCache) callLoader(ctx context.Context, loader func(context.Context) (interface{}, time.Duration, error)) (value interface{}, ttl time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.onPanic()(r)
+			value, ttl, err = nil, 0, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+	}()
+	return loader(ctx)
+}
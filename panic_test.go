@@ -0,0 +1,95 @@
+package lrucache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRU_PanicHandler(t *testing.T) {
+	var recovered []interface{}
+	handler := func(r interface{}) {
+		recovered = append(recovered, r)
+	}
+	evictCounter := 0
+	onEvictReason := func(k interface{}, v interface{}, reason EvictReason) {
+		evictCounter++
+	}
+	l, err := NewLRUCacheWithEvictReason(2, Expired, onEvictReason, WithPanicHandler(handler))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.SetOnEvict(func(k interface{}, v interface{}) {
+		panic("onEvict")
+	})
+	l.SetOnAdd(func(k interface{}, v interface{}) {
+		if k == 3 {
+			panic("onAdd")
+		}
+	})
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, Expired)
+	if len(recovered) != 2 || recovered[0] != "onAdd" || recovered[1] != "onEvict" {
+		t.Fatalf("bad recovered panics: %v", recovered)
+	}
+	if evictCounter != 1 || l.Len() != 2 || !l.Contains(3) {
+		t.Fatalf("the insert should complete: %v", l.Keys())
+	}
+
+	// the cache is not left locked nor inconsistent
+	l.PutWithCallback(2, "two", Expired, func(k interface{}, v interface{}) {
+		panic("entry")
+	})
+	l.Put(2, "new", Expired)
+	if v, _ := l.Get(2); v != "new" || l.Len() != 2 || l.Weight() != 2 {
+		t.Fatalf("bad value: %v", v)
+	}
+	if len(recovered) != 3 || recovered[2] != "entry" {
+		t.Fatalf("bad recovered panics: %v", recovered)
+	}
+}
+
+func TestLRU_PanicHandlerAsync(t *testing.T) {
+	var recovered []interface{}
+	l, err := NewLRUCache(1, Expired, func(k interface{}, v interface{}) {
+		panic(k)
+	}, WithAsyncEvict(true), WithPanicHandler(func(r interface{}) {
+		recovered = append(recovered, r)
+	}))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Clear()
+	if len(recovered) != 2 || recovered[0] != 1 || recovered[1] != 2 {
+		t.Fatalf("bad recovered panics: %v", recovered)
+	}
+}
+
+func TestLRU_LoaderPanic(t *testing.T) {
+	var recovered []interface{}
+	l, err := NewLRUCache(2, Expired, nil, WithPanicHandler(func(r interface{}) {
+		recovered = append(recovered, r)
+	}), WithLoader(LoaderFunc(func(key interface{}) (interface{}, time.Duration, error) {
+		panic("load")
+	})))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := l.Lookup(1); !errors.Is(err, ErrLoaderPanic) {
+		t.Fatalf("bad err: %v", err)
+	}
+	if _, ok := l.Get(1); ok || l.Contains(1) {
+		t.Fatalf("nothing should be loaded")
+	}
+	if _, err := l.GetOrLoad(2, func() (interface{}, error) {
+		panic("GetOrLoad")
+	}); !errors.Is(err, ErrLoaderPanic) {
+		t.Fatalf("bad err: %v", err)
+	}
+	if len(recovered) != 3 || recovered[2] != "GetOrLoad" {
+		t.Fatalf("bad recovered panics: %v", recovered)
+	}
+}
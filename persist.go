@@ -0,0 +1,127 @@
+package lrucache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// KV is an entry to add with Warm, a TTL of 0 uses the cache ttl like Put
+type KV struct {
+	Key   interface{}
+	Value interface{}
+	TTL   time.Duration
+}
+
+// savedEntry is the gob encoded form of an entry
+type savedEntry struct {
+	Key   interface{}
+	Value interface{}
+	// remaining lifetime, NoExpiration if the entry never expires
+	TTL time.Duration
+	// Weight is zero in data saved before PutWeighted, which counts as 1
+	Weight int
+	// Deadline is when the entry expires, zero if it never expires or in data saved
+	// before it was added, which is loaded with TTL from the time of the Load
+	Deadline time.Time
+}
+
+// Save writes the live entries with their expiry deadline to w using encoding/gob,
+// from oldest to newest. The concrete types of keys and values must be registered
+// with gob.Register.
+func (c *LruCache) Save(w io.Writer) error {
+	c.readLock()
+	entries := make([]savedEntry, 0, len(c.cache))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		saved := savedEntry{Key: kv.key, Value: kv.value, TTL: kv.remaining(c.now()), Weight: kv.weight}
+		if kv.ttl != nil {
+			saved.Deadline = *kv.ttl
+		}
+		entries = append(entries, saved)
+	}
+	c.lock.RUnlock()
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Load adds the entries written by Save to the cache, keeping their order and expiry deadline
+// by the cache clock. Entries which expired since they were saved are skipped, and only the
+// most recent ones are kept when there are more entries than the cache size.
+func (c *LruCache) Load(r io.Reader) error {
+	var entries []savedEntry
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	c.writeLock()
+	defer c.writeUnlock()
+	if len(entries) > c.size {
+		entries = entries[len(entries)-c.size:]
+	}
+	for _, e := range entries {
+		var lifetime time.Duration
+		if !e.Deadline.IsZero() {
+			if lifetime = e.Deadline.Sub(c.now()); lifetime <= 0 {
+				continue
+			}
+		} else if e.TTL != NoExpiration {
+			if e.TTL <= 0 {
+				continue
+			}
+			lifetime = e.TTL
+		}
+		c.set(&entry{key: e.Key, value: e.Value, lifetime: lifetime, weight: e.Weight})
+	}
+	return nil
+}
+
+// Warm adds the entries in order under a single lock, for example to seed the cache at startup.
+// When there are more entries than the cache size only the last ones are kept, the earlier
+// ones are skipped without firing onEvict, while the entries already cached are evicted
+// as usual to make room. Nothing is added and ErrUncomparableKey is returned if a key can't
+// be a map key.
+func (c *LruCache) Warm(entries []KV) error {
+	for _, kv := range entries {
+		if !comparableKey(kv.Key) {
+			return fmt.Errorf("%w: %T", ErrUncomparableKey, kv.Key)
+		}
+	}
+	c.writeLock()
+	defer c.writeUnlock()
+	if len(entries) > c.size {
+		entries = entries[len(entries)-c.size:]
+	}
+	for _, kv := range entries {
+		c.put(kv.Key, kv.Value, kv.TTL)
+	}
+	return nil
+}
+
+// jsonEntry is the JSON form of an entry
+type jsonEntry struct {
+	Key       interface{} `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+}
+
+// MarshalJSON encodes the live entries from oldest to newest as an array of
+// {"key", "value", "expires_at"} objects, expires_at is null for the entries which never expire.
+// The keys and values are encoded with encoding/json, which returns an error for the values
+// it can't encode, like channels or functions.
+func (c *LruCache) MarshalJSON() ([]byte, error) {
+	c.readLock()
+	entries := make([]jsonEntry, 0, len(c.cache))
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*entry)
+		if c.expired(kv) || kv.miss {
+			continue
+		}
+		entries = append(entries, jsonEntry{Key: kv.key, Value: kv.value, ExpiresAt: kv.info(c.now(), c.epoch).ExpiresAt})
+	}
+	c.lock.RUnlock()
+	return json.Marshal(entries)
+}
@@ -0,0 +1,185 @@
+package lrucache
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLRU_SaveLoad(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(0, "zero", 10*time.Millisecond)
+	l.Put(1, "one", Expired)
+	l.Put(2, "two", 0)
+	l.Put(3, "three", Expired)
+	l.Get(1)
+	time.Sleep(20 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l2, err := NewLRUCache(2, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l2.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	keys := l2.Keys()
+	if len(keys) != 2 || keys[0] != 3 || keys[1] != 1 {
+		t.Fatalf("should keep the most recent entries in order: %v", keys)
+	}
+	if d, ok := l2.TTL(1); !ok || d <= 0 || d > Expired {
+		t.Fatalf("bad ttl for 1: %v", d)
+	}
+
+	l3, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l3.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l3.Len() != 3 || l3.Contains(0) {
+		t.Fatalf("expired entry should not be saved: %v", l3.Keys())
+	}
+	if d, ok := l3.TTL(2); !ok || d != NoExpiration {
+		t.Fatalf("2 should never expire: %v", d)
+	}
+	if v, ok := l3.Get(2); !ok || v != "two" {
+		t.Fatalf("bad value for 2: %v", v)
+	}
+}
+
+func TestLRU_SaveLoadDeadline(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Second)
+	l.Put(2, 2, 2*time.Hour)
+	l.Put(3, 3, 0)
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// the deadlines are kept across the time between Save and Load
+	clock.Advance(time.Hour)
+	l2, err := NewLRUCache(4, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l2.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if l2.Contains(1) {
+		t.Fatalf("1 expired since it was saved")
+	}
+	if d, ok := l2.TTL(2); !ok || d != time.Hour {
+		t.Fatalf("bad ttl for 2: %v", d)
+	}
+	if d, ok := l2.TTL(3); !ok || d != NoExpiration {
+		t.Fatalf("3 should never expire: %v", d)
+	}
+}
+
+func TestLRU_LoadInvalid(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.Load(bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Fatalf("should fail to decode")
+	}
+}
+
+func TestLRU_SaveLoadNil(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, nil, Expired)
+
+	var buf bytes.Buffer
+	if err := l.Save(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l2, _ := NewLRUCache(4, Expired, nil)
+	if err := l2.Load(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if v, ok := l2.Get(1); !ok || v != nil {
+		t.Fatalf("nil should be loaded: %v, %v", v, ok)
+	}
+}
+
+func TestLRU_Warm(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(3, Expired, onEvicted, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(0, 0, Expired)
+
+	err = l.Warm([]KV{{Key: 1, Value: 1}, {Key: 2, Value: 2}, {Key: 3, Value: 3, TTL: 10 * time.Millisecond}, {Key: 4, Value: 4}})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 2 || keys[1] != 3 || keys[2] != 4 {
+		t.Fatalf("the last entries should be kept: %v", keys)
+	}
+	if evictCounter != 1 {
+		t.Fatalf("bad evict count: %v", evictCounter)
+	}
+	clock.Advance(20 * time.Millisecond)
+	if l.Contains(3) {
+		t.Fatalf("3 should be expired")
+	}
+
+	if err := l.Warm([]KV{{Key: 5, Value: 5}, {Key: []int{1}, Value: 6}}); err == nil {
+		t.Fatalf("should reject a slice key")
+	}
+	if l.Contains(5) {
+		t.Fatalf("nothing should be added")
+	}
+}
+
+func TestLRU_MarshalJSON(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put("a", 1, time.Minute)
+	l.Put("b", map[string]string{"x": "y"}, 0)
+	l.Put("c", 3, time.Second)
+	l.PutMiss("d", time.Minute)
+	clock.Advance(2 * time.Second)
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := `[{"key":"a","value":1,"expires_at":"2020-01-01T00:01:00Z"},{"key":"b","value":{"x":"y"},"expires_at":null}]`
+	if string(b) != want {
+		t.Fatalf("bad json: %s", b)
+	}
+
+	l.Put("e", make(chan int), 0)
+	if _, err := json.Marshal(l); err == nil {
+		t.Fatalf("should fail on a channel value")
+	}
+}
@@ -0,0 +1,50 @@
+package lrucache
+
+// Pin exempts a live key from the evictions made to fit the cache size, it may still expire
+// or be removed. Evicting walks past the pinned entries to the oldest unpinned one, so when
+// the pinned entries fill the cache a Put evicts the entry it adds. The cache only grows
+// beyond its size when the pinned entries alone don't fit, for example after Resize.
+// The pin is kept when the value is replaced. It returns false if the key is missing or expired.
+func (c *LruCache) Pin(key interface{}) bool {
+	return c.setPinned(key, true)
+}
+
+// Unpin makes a pinned key evictable again, the cache is not shrunk until the next insert.
+// It returns false if the key is missing or expired.
+func (c *LruCache) Unpin(key interface{}) bool {
+	return c.setPinned(key, false)
+}
+
+// setPinned sets the pin of a live key
+func (c *LruCache) setPinned(key interface{}, pinned bool) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return false
+	}
+	kv := ent.Value.(*entry)
+	if c.expired(kv) {
+		c.removeElement(ent, ReasonExpired)
+		return false
+	}
+	if kv.miss {
+		return false
+	}
+	if kv.pinned != pinned {
+		kv.pinned = pinned
+		if pinned {
+			c.pinned++
+		} else {
+			c.pinned--
+		}
+	}
+	return true
+}
+
+// PinnedCount returns the number of pinned entries.
+func (c *LruCache) PinnedCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.pinned
+}
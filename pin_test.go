@@ -0,0 +1,81 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_Pin(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(3, Expired, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, Expired)
+	if !l.Pin(1) || !l.Pin(1) || l.Pin(4) {
+		t.Fatalf("bad pin")
+	}
+	if l.PinnedCount() != 1 {
+		t.Fatalf("bad pinned count: %v", l.PinnedCount())
+	}
+
+	// eviction walks past the pinned 1
+	l.Put(4, 4, Expired)
+	if !l.Contains(1) || l.Contains(2) {
+		t.Fatalf("2 should be evicted instead of 1")
+	}
+	l.Put(1, 10, Expired)
+	l.Pin(3)
+	l.Pin(4)
+	if l.PinnedCount() != 3 {
+		t.Fatalf("update should keep the pin: %v", l.PinnedCount())
+	}
+	if n := l.Snapshot().PinnedCount(); n != 3 {
+		t.Fatalf("snapshot should keep the pins: %v", n)
+	}
+
+	// all pinned, the new entry is evicted
+	l.Put(5, 5, Expired)
+	if l.Contains(5) || l.Len() != 3 {
+		t.Fatalf("5 should be rejected")
+	}
+
+	// pinned entries still expire
+	l.Unpin(4)
+	l.Put(3, 3, 10*time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
+	if l.Pin(3) || l.PinnedCount() != 1 {
+		t.Fatalf("3 should be expired: %v", l.PinnedCount())
+	}
+	l.Put(5, 5, Expired)
+	l.Put(6, 6, Expired)
+	if keys := l.Keys(); len(keys) != 3 || keys[0] != 1 || keys[1] != 5 || keys[2] != 6 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+	l.Remove(1)
+	if l.PinnedCount() != 0 {
+		t.Fatalf("bad pinned count: %v", l.PinnedCount())
+	}
+}
+
+func TestLRU_ResizePinned(t *testing.T) {
+	l, err := NewLRUCache(3, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		l.Put(i, i, Expired)
+		l.Pin(i)
+	}
+	if evicted, _ := l.Resize(1); evicted != 0 || l.Len() != 3 {
+		t.Fatalf("pinned entries should be kept: %v", evicted)
+	}
+	l.Unpin(0)
+	l.Unpin(1)
+	l.Put(3, 3, Expired)
+	if keys := l.Keys(); len(keys) != 1 || keys[0] != 2 {
+		t.Fatalf("bad keys: %v", keys)
+	}
+}
@@ -0,0 +1,55 @@
+package lrucache
+
+import (
+	"context"
+	"time"
+)
+
+// WithRefreshAhead makes Get reload an entry in the background when it is hit within
+// window of its expiry, returning the current value meanwhile. Only one reload runs per key.
+// The reloaded value replaces the entry with a new deadline from the ttl it was stored with,
+// unless the entry was removed meanwhile. If reload fails the current value is kept until it expires.
+func WithRefreshAhead(window time.Duration, reload func(key interface{}) (interface{}, error)) Option {
+	return func(c *LruCache) {
+		c.refreshWindow = window
+		c.reload = reload
+	}
+}
+
+// refreshDue returns true if e is live and should be reloaded ahead of its expiry, the lock must be held
+func (c *LruCache) refreshDue(e *entry) bool {
+	return c.reload != nil && e.ttl != nil && e.remaining(c.now()) < c.refreshWindow
+}
+
+// refresh starts a reload of key unless one is already in flight
+func (c *LruCache) refresh(key interface{}) {
+	c.loadLock.Lock()
+	defer c.loadLock.Unlock()
+	if _, ok := c.refreshes[key]; ok {
+		return
+	}
+	if c.refreshes == nil {
+		c.refreshes = make(map[interface{}]struct{})
+	}
+	c.refreshes[key] = struct{}{}
+	go func() {
+		defer func() {
+			c.loadLock.Lock()
+			delete(c.refreshes, key)
+			c.loadLock.Unlock()
+		}()
+		value, _, err := c.callLoader(context.Background(), func(context.Context) (interface{}, time.Duration, error) {
+			value, err := c.reload(key)
+			return value, 0, err
+		})
+		if err != nil {
+			return
+		}
+		c.writeLock()
+		defer c.writeUnlock()
+		if ent, ok := c.cache[key]; ok {
+			kv := ent.Value.(*entry)
+			c.set(&entry{key: key, value: value, lifetime: kv.lifetime, onEvict: kv.onEvict, weight: kv.weight, meta: kv.meta, version: kv.version, stored: true})
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+package lrucache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond for up to a second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("condition not met")
+}
+
+func TestLRU_RefreshAhead(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var calls int32
+	release := make(chan struct{})
+	reload := func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return key.(int) * 10, nil
+	}
+	l, err := NewLRUCache(4, time.Minute, nil, WithClock(clock), WithRefreshAhead(10*time.Second, reload))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+
+	clock.Advance(40 * time.Second)
+	if v, _ := l.Get(1); v != 1 || atomic.LoadInt32(&calls) != 0 {
+		t.Fatalf("should not refresh yet: %v", v)
+	}
+
+	clock.Advance(15 * time.Second)
+	for i := 0; i < 3; i++ {
+		if v, ok := l.Get(1); !ok || v != 1 {
+			t.Fatalf("the current value should be returned: %v", v)
+		}
+	}
+	close(release)
+	waitFor(t, func() bool {
+		v, _ := l.Peek(1)
+		return v == 10
+	})
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("bad reload calls: %v", n)
+	}
+	if ttl, _ := l.TTL(1); ttl != time.Minute {
+		t.Fatalf("refreshed entry should get a new deadline: %v", ttl)
+	}
+}
+
+func TestLRU_RefreshAheadError(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var calls int32
+	reload := func(key interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("backend down")
+	}
+	l, err := NewLRUCache(4, time.Minute, nil, WithClock(clock), WithRefreshAhead(10*time.Second, reload))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+
+	clock.Advance(55 * time.Second)
+	l.Get(1)
+	waitFor(t, func() bool {
+		l.loadLock.Lock()
+		defer l.loadLock.Unlock()
+		return atomic.LoadInt32(&calls) == 1 && len(l.refreshes) == 0
+	})
+	if v, ok := l.Get(1); !ok || v != 1 {
+		t.Fatalf("the old value should be kept: %v", v)
+	}
+
+	clock.Advance(10 * time.Second)
+	if _, ok := l.Get(1); ok {
+		t.Fatalf("1 should be expired")
+	}
+}
@@ -0,0 +1,146 @@
+package lrucache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ShardedLruCache spreads keys across independent LruCache shards to reduce lock contention
+type ShardedLruCache struct {
+	shards []*LruCache
+}
+
+// NewShardedLRUCache creates a cache of the given total size split into shards,
+// each shard holds totalSize/shards entries and is configured with opts.
+// The options starting goroutines, like WithWriteBehind, start them in every shard,
+// call Close to stop them.
+func NewShardedLRUCache(shards, totalSize int, ttl time.Duration, onEvict EvictCallback, opts ...Option) (*ShardedLruCache, error) {
+	if shards <= 0 {
+		return nil, errors.New("Must provide a positive number of shards")
+	}
+	if totalSize < shards {
+		return nil, errors.New("Must provide a size of at least one entry per shard")
+	}
+	c := &ShardedLruCache{shards: make([]*LruCache, shards)}
+	for i := range c.shards {
+		shard, err := NewLRUCache(totalSize/shards, ttl, onEvict, opts...)
+		if err != nil {
+			return nil, err
+		}
+		c.shards[i] = shard
+	}
+	return c, nil
+}
+
+// shard returns the shard holding key
+func (c *ShardedLruCache) shard(key interface{}) *LruCache {
+	return c.shards[hashKey(key)%uint64(len(c.shards))]
+}
+
+// hashKey hashes a key with fnv, keys other than strings and integers are hashed by their %v form
+func hashKey(key interface{}) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	switch k := key.(type) {
+	case string:
+		h.Write([]byte(k))
+	case int:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case int8:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case int16:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case int32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case uint:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case uint8:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case uint16:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case uint32:
+		binary.LittleEndian.PutUint64(buf[:], uint64(k))
+		h.Write(buf[:])
+	case uint64:
+		binary.LittleEndian.PutUint64(buf[:], k)
+		h.Write(buf[:])
+	default:
+		fmt.Fprintf(h, "%v", key)
+	}
+	return h.Sum64()
+}
+
+// Get a key's value from the cache.
+func (c *ShardedLruCache) Get(key interface{}) (value interface{}, ok bool) {
+	return c.shard(key).Get(key)
+}
+
+// Put adds the value to the cache at key with the specified maximum duration.
+func (c *ShardedLruCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
+	return c.shard(key).Put(key, value, ttl)
+}
+
+// Remove removes the provided key from the cache.
+func (c *ShardedLruCache) Remove(key interface{}) bool {
+	return c.shard(key).Remove(key)
+}
+
+// Contains Check if a key exsists in cache without updating the recent-ness.
+func (c *ShardedLruCache) Contains(key interface{}) bool {
+	return c.shard(key).Contains(key)
+}
+
+// Peek returns a key's value without updating the recent-ness.
+func (c *ShardedLruCache) Peek(key interface{}) (value interface{}, ok bool) {
+	return c.shard(key).Peek(key)
+}
+
+// Len returns the number of items in all the shards.
+func (c *ShardedLruCache) Len() int {
+	n := 0
+	for _, shard := range c.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Keys return all the keys in cache, shard by shard and from oldest to newest within a shard
+func (c *ShardedLruCache) Keys() []interface{} {
+	var keys []interface{}
+	for _, shard := range c.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Clear remove all the keys in cache
+func (c *ShardedLruCache) Clear() {
+	for _, shard := range c.shards {
+		shard.Clear()
+	}
+}
+
+// Close closes every shard like LruCache.Close, flushing their write-behind queues and
+// stopping their background goroutines. It returns the first error of the shards.
+func (c *ShardedLruCache) Close() error {
+	var err error
+	for _, shard := range c.shards {
+		if shardErr := shard.Close(); shardErr != nil && err == nil {
+			err = shardErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package lrucache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedLRU(t *testing.T) {
+	l, err := NewShardedLRUCache(4, 64, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		l.Put(i, i, Expired)
+		l.Put(fmt.Sprintf("key%d", i), i, Expired)
+	}
+	if l.Len() > 64 || len(l.Keys()) != l.Len() {
+		t.Fatalf("bad len: %v, keys: %v", l.Len(), len(l.Keys()))
+	}
+	for _, k := range l.Keys() {
+		if _, ok := l.Peek(k); !ok {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	l.Put(100, 100, Expired)
+	if v, ok := l.Get(100); !ok || v != 100 {
+		t.Fatalf("bad value for 100: %v", v)
+	}
+	if !l.Contains(100) || !l.Remove(100) || l.Contains(100) {
+		t.Fatalf("100 should be removed")
+	}
+	l.Clear()
+	if l.Len() != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func TestShardedLRU_Invalid(t *testing.T) {
+	if _, err := NewShardedLRUCache(0, 16, Expired, nil); err == nil {
+		t.Fatalf("should reject zero shards")
+	}
+	if _, err := NewShardedLRUCache(8, 4, Expired, nil); err == nil {
+		t.Fatalf("should reject less than one entry per shard")
+	}
+}
+
+func TestShardedLRU_Close(t *testing.T) {
+	st := &store{}
+	l, err := NewShardedLRUCache(4, 64, 0, nil, WithWriteBehind(st.flush, time.Hour, 0), WithEvictWorkers(1))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 32; i++ {
+		l.Put(i, i, 0)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	n := 0
+	for _, batch := range st.written() {
+		n += len(batch)
+	}
+	if n != 32 {
+		t.Fatalf("Close should flush every shard: %v", n)
+	}
+
+	st = &store{fail: true}
+	l, err = NewShardedLRUCache(2, 4, 0, nil, WithWriteBehind(st.flush, time.Hour, 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	if err := l.Close(); err == nil {
+		t.Fatalf("Close should return the flush error")
+	}
+}
+
+func TestShardedLRU_Concurrent(t *testing.T) {
+	l, err := NewShardedLRUCache(8, 256, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Put(g*1000+i, i, Expired)
+				l.Get(g*1000 + i/2)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if l.Len() > 256 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+}
+
+func BenchmarkShardedLRU_Parallel(b *testing.B) {
+	l, err := NewShardedLRUCache(16, 8192, Expired, nil)
+	if err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 8192; i++ {
+		l.Put(i, i, Expired)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			l.Get(i % 8192)
+			i++
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package lrucache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrInvalidSize is returned by PutE when sizeOf panics or returns a negative size
+	ErrInvalidSize = errors.New("lrucache: invalid entry size")
+	// ErrTooLarge is returned by PutE for a value which can never fit in the cache
+	ErrTooLarge = errors.New("lrucache: entry too large")
+	// ErrUncomparableKey is returned by PutE and Warm for a key which can't be a map key,
+	// like a slice or a struct holding one, with which Put panics
+	ErrUncomparableKey = errors.New("lrucache: key is not comparable")
+	// ErrCacheFull is returned by PutE and Increment for a new key when the cache is full under PolicyReject
+	ErrCacheFull = errors.New("lrucache: cache is full")
+)
+
+// SizeFunc returns the size in bytes of a cache entry
+type SizeFunc func(key interface{}, value interface{}) int64
+
+// NewSizedLRUCache creates an expiring cache bounded by the total size of its entries
+// as reported by sizeOf, instead of their count. The oldest entries are evicted until
+// the total fits in maxBytes. A value larger than maxBytes is never cached, Put drops
+// it and removes any previous value stored at its key. A value for which sizeOf panics
+// or returns a negative size is dropped too, leaving any previous value in place.
+func NewSizedLRUCache(maxBytes int64, sizeOf SizeFunc, ttl time.Duration, onEvict EvictCallback, opts ...Option) (*LruCache, error) {
+	if maxBytes <= 0 {
+		return nil, errNonPositiveSize
+	}
+	if sizeOf == nil {
+		return nil, errors.New("Must provide a size function to cache")
+	}
+	c, err := NewLRUCache(math.MaxInt, ttl, onEvict, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.maxBytes = maxBytes
+	c.sizeOf = sizeOf
+	return c, nil
+}
+
+// SizeBytes returns the total size of the entries in a sized cache.
+func (c *LruCache) SizeBytes() int64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.bytes
+}
+
+// PutE adds the value like Put, returning ErrTooLarge if the value can never fit in the cache,
+// ErrInvalidSize if sizeOf panics or returns a negative size, ErrUncomparableKey for a key
+// with which Put would panic and ErrCacheFull for a new key rejected by WithFullPolicy,
+// in the last three cases the cache is unchanged.
+func (c *LruCache) PutE(key, value interface{}, ttl time.Duration) error {
+	if !comparableKey(key) {
+		return fmt.Errorf("%w: %T", ErrUncomparableKey, key)
+	}
+	c.writeLock()
+	defer c.writeUnlock()
+	_, err := c.add(&entry{key: key, value: value, lifetime: c.lifetime(ttl)})
+	return err
+}
+
+// entrySize returns the size of e reported by sizeOf, turning a panic or a negative size into an error
+func (c *LruCache) entrySize(e *entry) (bytes int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			bytes, err = 0, fmt.Errorf("%w: sizeOf panicked: %v", ErrInvalidSize, r)
+		}
+	}()
+	bytes = c.sizeOf(e.key, e.value)
+	if bytes < 0 {
+		return 0, fmt.Errorf("%w: sizeOf returned %d", ErrInvalidSize, bytes)
+	}
+	return bytes, nil
+}
+
+// comparableKey returns true if key can be used as a map key without panicking
+func comparableKey(key interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = map[interface{}]struct{}{key: {}}
+	return true
+}
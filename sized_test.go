@@ -0,0 +1,156 @@
+package lrucache
+
+import (
+	"errors"
+	"testing"
+)
+
+func stringSize(k interface{}, v interface{}) int64 {
+	return int64(len(v.(string)))
+}
+
+func TestSizedLRU(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewSizedLRUCache(10, stringSize, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, "aaaa", Expired)
+	l.Put(2, "bbbb", Expired)
+	if l.SizeBytes() != 8 || l.Len() != 2 {
+		t.Fatalf("bad size: %v, len: %v", l.SizeBytes(), l.Len())
+	}
+	if !l.Put(3, "cccc", Expired) || evictCounter != 1 {
+		t.Fatalf("should have an eviction")
+	}
+	if l.Contains(1) || l.SizeBytes() != 8 {
+		t.Fatalf("1 should be evicted, size: %v", l.SizeBytes())
+	}
+
+	// growing an existing entry evicts the older ones
+	l.Put(3, "cccccccc", Expired)
+	if l.Contains(2) || l.SizeBytes() != 8 || l.Len() != 1 {
+		t.Fatalf("2 should be evicted, size: %v, len: %v", l.SizeBytes(), l.Len())
+	}
+
+	if l.Put(4, "ddddddddddd", Expired) || l.Contains(4) {
+		t.Fatalf("too large value should be dropped")
+	}
+	l.Put(3, "ccccccccccc", Expired)
+	if l.Contains(3) || l.SizeBytes() != 0 {
+		t.Fatalf("too large update should remove 3, size: %v", l.SizeBytes())
+	}
+
+	l.Put(5, "eeee", Expired)
+	l.Remove(5)
+	l.Put(6, "ffff", Expired)
+	l.Clear()
+	if l.SizeBytes() != 0 {
+		t.Fatalf("bad size: %v", l.SizeBytes())
+	}
+}
+
+func TestSizedLRU_Update(t *testing.T) {
+	l, err := NewSizedLRUCache(10, stringSize, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, "aaaa", Expired)
+	if l.Update(1, "aaaaaaaaaaa") || l.Contains(1) {
+		t.Fatalf("a too large update should remove 1 and return false")
+	}
+
+	// without the move to the front the grown entry is the oldest one
+	l.DisableRecencyUpdates(true)
+	l.Put(1, "aaaa", Expired)
+	l.Put(2, "bbbb", Expired)
+	if l.Update(1, "aaaaaaa") || l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("an update evicting 1 itself should return false")
+	}
+	if !l.Update(2, "bbbbbb") {
+		t.Fatalf("2 should be updated")
+	}
+	if v, ok := l.Get(2); !ok || v != "bbbbbb" || l.SizeBytes() != 6 {
+		t.Fatalf("bad value for 2: %v, size: %v", v, l.SizeBytes())
+	}
+}
+
+func TestSizedLRU_Invalid(t *testing.T) {
+	if _, err := NewSizedLRUCache(0, stringSize, Expired, nil); err == nil {
+		t.Fatalf("should reject a zero size")
+	}
+	if _, err := NewSizedLRUCache(10, nil, Expired, nil); err == nil {
+		t.Fatalf("should reject a nil size function")
+	}
+}
+
+func TestSizedLRU_PutE(t *testing.T) {
+	sizeOf := func(k interface{}, v interface{}) int64 {
+		if v == "negative" {
+			return -1
+		}
+		return stringSize(k, v)
+	}
+	l, err := NewSizedLRUCache(10, sizeOf, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := l.PutE(1, "aaaa", Expired); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.PutE(1, "negative", Expired); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("err: %v", err)
+	}
+	if err := l.PutE(1, 42, Expired); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(2, 42, Expired)
+	if v, ok := l.Get(1); !ok || v != "aaaa" || l.Contains(2) || l.SizeBytes() != 4 || l.Len() != 1 {
+		t.Fatalf("cache should be unchanged: %v, size: %v", v, l.SizeBytes())
+	}
+
+	if err := l.PutE(1, "aaaaaaaaaaa", Expired); err != ErrTooLarge {
+		t.Fatalf("err: %v", err)
+	}
+	if l.Contains(1) || l.SizeBytes() != 0 {
+		t.Fatalf("1 should be removed, size: %v", l.SizeBytes())
+	}
+}
+
+func TestLRU_PutEUncomparableKey(t *testing.T) {
+	l, err := NewLRUCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	type composite struct {
+		Name string
+		Tags []string
+	}
+	type point struct {
+		X, Y int
+	}
+	keys := []interface{}{[]byte("key"), composite{Name: "a"}, map[string]int{}, point{X: 1}, struct{ V interface{} }{[]int{1}}}
+	for _, key := range keys {
+		err := l.PutE(key, 1, Expired)
+		if _, ok := key.(point); ok {
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrUncomparableKey) {
+			t.Fatalf("%T should be rejected: %v", key, err)
+		}
+	}
+	if v, ok := l.Get(point{X: 1}); !ok || v != 1 || l.Len() != 1 {
+		t.Fatalf("struct key should be cached: %v", v)
+	}
+	if err := l.Warm([]KV{{Key: []byte("key")}}); !errors.Is(err, ErrUncomparableKey) {
+		t.Fatalf("err: %v", err)
+	}
+}
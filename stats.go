@@ -0,0 +1,73 @@
+package lrucache
+
+import (
+	"sync/atomic"
+)
+
+// CacheStats holds the counters collected by a LruCache
+type CacheStats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+	// Len and Cap are the current number of items and the cache size
+	Len int
+	Cap int
+}
+
+// Stats returns a snapshot of the cache counters.
+// Only Get counts hits and misses, Peek and Contains don't touch the counters.
+// Evictions counts entries dropped to make room, Expirations counts expired
+// entries removed on access.
+func (c *LruCache) Stats() CacheStats {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return CacheStats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+		Len:         c.evictList.Len(),
+		Cap:         c.size,
+	}
+}
+
+// ResetStats set all the counters back to zero
+func (c *LruCache) ResetStats() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+	atomic.StoreUint64(&c.expirations, 0)
+}
+
+// MetricKind tells how a metric should be exported
+type MetricKind int
+
+const (
+	// Counter metrics only go up, until ResetStats
+	Counter MetricKind = iota
+	// Gauge metrics are a current value which can go up and down
+	Gauge
+)
+
+// Metric is a single cache measurement reported by Collect
+type Metric struct {
+	Name  string
+	Help  string
+	Kind  MetricKind
+	Value float64
+}
+
+// Collect reports the cache counters and sizes to emit, one call per metric, so they can be
+// wired into a metrics pipeline such as an expvar map or a Prometheus collector without
+// depending on it. The metrics are hits, misses, evictions and expirations as counters,
+// len and cap as gauges.
+func (c *LruCache) Collect(emit func(Metric)) {
+	s := c.Stats()
+	emit(Metric{Name: "hits", Help: "Number of Get calls which found a live entry.", Kind: Counter, Value: float64(s.Hits)})
+	emit(Metric{Name: "misses", Help: "Number of Get calls which found no live entry.", Kind: Counter, Value: float64(s.Misses)})
+	emit(Metric{Name: "evictions", Help: "Number of entries evicted to make room.", Kind: Counter, Value: float64(s.Evictions)})
+	emit(Metric{Name: "expirations", Help: "Number of expired entries removed.", Kind: Counter, Value: float64(s.Expirations)})
+	emit(Metric{Name: "len", Help: "Number of entries in the cache.", Kind: Gauge, Value: float64(s.Len)})
+	emit(Metric{Name: "cap", Help: "Maximum number of entries in the cache.", Kind: Gauge, Value: float64(s.Cap)})
+}
@@ -0,0 +1,63 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_Stats(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, Expired)
+	l.Put(4, 4, 10*time.Millisecond)
+	l.Get(3)
+	l.Get(1)
+	l.Peek(3)
+	l.Contains(3)
+	time.Sleep(20 * time.Millisecond)
+	l.Get(4)
+
+	want := CacheStats{Hits: 1, Misses: 2, Evictions: 2, Expirations: 1, Len: 1, Cap: 2}
+	if s := l.Stats(); s != want {
+		t.Fatalf("bad stats: %+v", s)
+	}
+
+	l.ResetStats()
+	if s := l.Stats(); s != (CacheStats{Len: 1, Cap: 2}) {
+		t.Fatalf("stats should be reset: %+v", s)
+	}
+}
+
+func TestLRU_Collect(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	l.Put(3, 3, Expired)
+	l.Get(3)
+	l.Get(1)
+
+	got := make(map[string]Metric)
+	l.Collect(func(m Metric) {
+		got[m.Name] = m
+	})
+	want := map[string]float64{"hits": 1, "misses": 1, "evictions": 1, "expirations": 0, "len": 2, "cap": 2}
+	if len(got) != len(want) {
+		t.Fatalf("bad metrics: %v", got)
+	}
+	for name, value := range want {
+		if got[name].Value != value {
+			t.Fatalf("bad %s: %v", name, got[name].Value)
+		}
+	}
+	if got["hits"].Kind != Counter || got["len"].Kind != Gauge {
+		t.Fatalf("bad kinds: %v", got)
+	}
+}
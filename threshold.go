@@ -0,0 +1,18 @@
+package lrucache
+
+// SetFullThreshold calls cb once when Len()/Cap() reaches ratio after a write, with the
+// length and size at that time, and re-arms when entries are removed and the fill drops
+// back below ratio. cb runs after the lock is released, so it may call the cache.
+// A nil cb disables the notification. The caches bounded by bytes have no meaningful size.
+func (c *LruCache) SetFullThreshold(ratio float64, cb func(len, cap int)) {
+	c.writeLock()
+	defer c.writeUnlock()
+	c.fullRatio = ratio
+	c.onFull = cb
+	c.full = false
+}
+
+// overThreshold returns true if the fill reaches fullRatio, the lock must be held
+func (c *LruCache) overThreshold() bool {
+	return float64(c.evictList.Len()) >= c.fullRatio*float64(c.size)
+}
@@ -0,0 +1,44 @@
+package lrucache
+
+import (
+	"testing"
+)
+
+func TestLRU_FullThreshold(t *testing.T) {
+	l, err := NewLRUCache(10, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var calls [][2]int
+	l.SetFullThreshold(0.9, func(n, size int) {
+		calls = append(calls, [2]int{n, size})
+		// the lock is released
+		l.Len()
+	})
+
+	for i := 0; i < 8; i++ {
+		l.Put(i, i, Expired)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("should not be notified yet: %v", calls)
+	}
+	l.Put(8, 8, Expired)
+	l.Put(9, 9, Expired)
+	l.Put(10, 10, Expired)
+	if len(calls) != 1 || calls[0] != [2]int{9, 10} {
+		t.Fatalf("should be notified once: %v", calls)
+	}
+
+	l.Remove(10)
+	l.Remove(9)
+	l.Put(9, 9, Expired)
+	if len(calls) != 2 || calls[1] != [2]int{9, 10} {
+		t.Fatalf("should be notified again once re-armed: %v", calls)
+	}
+
+	l.Clear()
+	l.MPut(map[interface{}]interface{}{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9}, Expired)
+	if len(calls) != 3 {
+		t.Fatalf("MPut should notify: %v", calls)
+	}
+}
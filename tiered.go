@@ -0,0 +1,120 @@
+package lrucache
+
+import (
+	"time"
+)
+
+// TieredCache composes a small fast L1 cache in front of a larger slower L2 one.
+// Get checks L1 then L2, copying an L2 hit into L1. Each tier is thread safe, but an
+// operation spanning both is not atomic, a concurrent Put may land between the L1 and
+// L2 writes of another one.
+type TieredCache struct {
+	l1 Interface
+	l2 Interface
+	// writeBack is the L1 cache in write-back mode, nil in write-through mode
+	writeBack *LruCache
+}
+
+// NewTieredCache creates a write-through cache of l1 over l2: Put writes both tiers.
+func NewTieredCache(l1, l2 Interface) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2}
+}
+
+// NewWriteBackTieredCache creates a write-back cache of l1 over l2: Put writes l1 only, and
+// the live entries leaving l1, evicted, removed or replaced, are written to l2 with their
+// remaining ttl. The expired ones are dropped. A ttl of 0 given to Put is the l1 ttl.
+func NewWriteBackTieredCache(l1 *LruCache, l2 Interface) *TieredCache {
+	return &TieredCache{l1: l1, l2: l2, writeBack: l1}
+}
+
+// Get returns the value of key from L1, or from L2 storing it into L1.
+// The L1 copy gets the remaining ttl of the L2 entry if L2 reports it with a TTL method
+// like LruCache has, otherwise the L1 ttl.
+func (c *TieredCache) Get(key interface{}) (value interface{}, ok bool) {
+	if value, ok = c.l1.Get(key); ok {
+		return value, true
+	}
+	if value, ok = c.l2.Get(key); !ok {
+		return nil, false
+	}
+	var ttl time.Duration
+	if l2, isTTL := c.l2.(interface {
+		TTL(key interface{}) (time.Duration, bool)
+	}); isTTL {
+		if remaining, live := l2.TTL(key); live {
+			ttl = remaining
+		}
+	}
+	c.put(key, value, ttl)
+	return value, true
+}
+
+// Put adds the value at key for ttl to L1, and to L2 in write-through mode.
+// It returns true if an entry was evicted from either tier.
+func (c *TieredCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
+	evicted := c.put(key, value, ttl)
+	if c.writeBack == nil {
+		evicted = c.l2.Put(key, value, ttl) || evicted
+	}
+	return evicted
+}
+
+// put adds the value to L1, to be written to L2 when it leaves L1 in write-back mode
+func (c *TieredCache) put(key interface{}, value interface{}, ttl time.Duration) bool {
+	l1 := c.writeBack
+	if l1 == nil {
+		return c.l1.Put(key, value, ttl)
+	}
+	lifetime := ttl
+	if lifetime != NoExpiration && lifetime <= 0 {
+		lifetime = l1.DefaultTTL()
+	}
+	stored := l1.now()
+	return l1.PutWithCallback(key, value, ttl, func(key, value interface{}) {
+		remaining := NoExpiration
+		if lifetime > 0 {
+			if remaining = lifetime - l1.now().Sub(stored); remaining <= 0 {
+				return
+			}
+		}
+		c.l2.Put(key, value, remaining)
+	})
+}
+
+// Remove removes key from both tiers, it returns true if either held it.
+func (c *TieredCache) Remove(key interface{}) bool {
+	// in write-back mode removing from L1 writes the value to L2, so L1 goes first
+	removed := c.l1.Remove(key)
+	return c.l2.Remove(key) || removed
+}
+
+// Contains returns true if either tier holds key, without updating the recent-ness.
+func (c *TieredCache) Contains(key interface{}) bool {
+	return c.l1.Contains(key) || c.l2.Contains(key)
+}
+
+// Len returns the number of distinct keys in both tiers, it costs a call to Keys.
+func (c *TieredCache) Len() int {
+	return len(c.Keys())
+}
+
+// Keys returns the keys of L1 followed by the keys only in L2.
+func (c *TieredCache) Keys() []interface{} {
+	keys := c.l1.Keys()
+	seen := make(map[interface{}]struct{}, len(keys))
+	for _, k := range keys {
+		seen[k] = struct{}{}
+	}
+	for _, k := range c.l2.Keys() {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Clear removes all the keys of both tiers.
+func (c *TieredCache) Clear() {
+	c.l1.Clear()
+	c.l2.Clear()
+}
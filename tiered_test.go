@@ -0,0 +1,78 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredCache_WriteThrough(t *testing.T) {
+	l1, _ := NewLRUCache(2, Expired, nil)
+	l2, _ := NewLRUCache(8, Expired, nil)
+	c := NewTieredCache(l1, l2)
+	for i := 0; i < 4; i++ {
+		c.Put(i, i, Expired)
+	}
+	if l1.Len() != 2 || l2.Len() != 4 || c.Len() != 4 {
+		t.Fatalf("bad lens: %d %d %d", l1.Len(), l2.Len(), c.Len())
+	}
+	if l1.Contains(0) {
+		t.Fatalf("0 should be evicted from l1")
+	}
+
+	// an L2 hit is promoted into L1 with its remaining ttl
+	l2.Put(5, 5, time.Minute)
+	if v, ok := c.Get(5); !ok || v != 5 {
+		t.Fatalf("bad value: %v %v", v, ok)
+	}
+	if ttl, ok := l1.TTL(5); !ok || ttl > time.Minute || ttl < time.Minute-time.Second {
+		t.Fatalf("5 should be promoted with its ttl: %v %v", ttl, ok)
+	}
+	if _, ok := c.Get(9); ok {
+		t.Fatalf("9 should miss")
+	}
+
+	if !c.Remove(5) || l1.Contains(5) || l2.Contains(5) || c.Remove(5) {
+		t.Fatalf("5 should be removed from both tiers")
+	}
+	c.Clear()
+	if c.Len() != 0 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+}
+
+func TestTieredCache_WriteBack(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l1, _ := NewLRUCache(2, time.Minute, nil, WithClock(clock))
+	l2, _ := NewLRUCache(8, Expired, nil, WithClock(clock))
+	c := NewWriteBackTieredCache(l1, l2)
+	c.Put(1, 1, 0)
+	c.Put(2, 2, time.Second)
+	if l2.Len() != 0 {
+		t.Fatalf("l2 should not be written yet: %v", l2.Keys())
+	}
+	clock.Advance(10 * time.Second)
+
+	// 1 is demoted with its remaining ttl, the expired 2 is dropped
+	c.Put(3, 3, NoExpiration)
+	c.Put(4, 4, NoExpiration)
+	if ttl, ok := l2.TTL(1); !ok || ttl != 50*time.Second {
+		t.Fatalf("1 should be written back: %v %v", ttl, ok)
+	}
+	if l2.Contains(2) {
+		t.Fatalf("2 should be dropped")
+	}
+
+	// promoting 1 back demotes 3 which never expires
+	if v, ok := c.Get(1); !ok || v != 1 || !l1.Contains(1) {
+		t.Fatalf("1 should be promoted: %v %v", v, ok)
+	}
+	if ttl, ok := l2.TTL(3); !ok || ttl != NoExpiration {
+		t.Fatalf("3 should be written back: %v %v", ttl, ok)
+	}
+	if c.Len() != 3 {
+		t.Fatalf("bad len: %d", c.Len())
+	}
+	if !c.Remove(4) || l2.Contains(4) {
+		t.Fatalf("4 should be removed from both tiers")
+	}
+}
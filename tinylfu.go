@@ -0,0 +1,131 @@
+package lrucache
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// sketchDepth is the number of rows of the count-min sketch
+	sketchDepth = 4
+	// sketchMinWidth keeps the collisions rare for the small caches
+	sketchMinWidth = 64
+	// sketchMaxWidth bounds the counters per row for the very large caches
+	sketchMaxWidth = 1 << 20
+	// sketchMaxCount is where the counters saturate
+	sketchMaxCount = 15
+)
+
+// sketch is a count-min sketch estimating the access frequency of the keys.
+// The counters are halved every sampleSize accesses, so the old accesses fade away.
+type sketch struct {
+	lock       sync.Mutex
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+// newSketch creates a sketch with 4 counters per entry of a cache of size entries
+func newSketch(size int) *sketch {
+	width := sketchMinWidth
+	for width < 4*size && width < sketchMaxWidth {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), sampleSize: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the counter of row i for the key hashed to h
+func (s *sketch) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & s.mask
+}
+
+// increment counts an access to key
+func (s *sketch) increment(key interface{}) {
+	h := hashKey(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < sketchMaxCount {
+			*c++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.additions /= 2
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] /= 2
+			}
+		}
+	}
+}
+
+// estimate returns the estimated access frequency of key
+func (s *sketch) estimate(key interface{}) uint8 {
+	h := hashKey(key)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// WithAdmissionPolicy sets whether a new key must be used more often than the entry it would
+// evict to be cached, like TinyLFU. The accesses of Get and Put are counted in a small
+// count-min sketch whose counts halve over time. A Put rejected this way neither stores
+// nor evicts anything, so a scan of keys used once can't flush the frequently used ones.
+func WithAdmissionPolicy(enabled bool) Option {
+	return func(c *LruCache) {
+		c.admission = nil
+		if enabled {
+			c.admission = newSketch(c.size)
+		}
+	}
+}
+
+// recordAccess counts an access to key for the admission policy
+func (c *LruCache) recordAccess(key interface{}) {
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+}
+
+// admit returns true if the new entry e can be cached, the write lock must be held
+func (c *LruCache) admit(e *entry) bool {
+	if c.admission == nil || e.miss {
+		return true
+	}
+	c.admission.increment(e.key)
+	if c.fits(e) {
+		return true
+	}
+	victim := c.victim()
+	return victim == nil || c.admission.estimate(e.key) > c.admission.estimate(victim.key)
+}
+
+// victim returns the entry removeOldest would evict to make room, or nil if it would
+// reclaim an expired entry or can't evict anything, the write lock must be held
+func (c *LruCache) victim() *entry {
+	if c.oldestExpired() != nil {
+		return nil
+	}
+	ent, next := c.evictList.Back(), (*list.Element).Prev
+	if c.policy == MRU {
+		ent, next = c.evictList.Front(), (*list.Element).Next
+	}
+	for ; ent != nil; ent = next(ent) {
+		if kv := ent.Value.(*entry); !kv.pinned {
+			return kv
+		}
+	}
+	return nil
+}
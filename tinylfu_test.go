@@ -0,0 +1,125 @@
+package lrucache
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestLRU_AdmissionPolicy(t *testing.T) {
+	l, err := NewLRUCache(4, 0, nil, WithAdmissionPolicy(true))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, 0)
+		for j := 0; j < 4; j++ {
+			l.Get(i)
+		}
+	}
+	for i := 100; i < 200; i++ {
+		if l.Put(i, i, 0) {
+			t.Fatalf("%d should not evict a hot key", i)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if _, ok := l.Get(i); !ok {
+			t.Fatalf("%d should survive the scan", i)
+		}
+	}
+	if l.Contains(100) {
+		t.Fatalf("100 should not be admitted")
+	}
+
+	// A key used more often than the victim gets in
+	for j := 0; j < 10; j++ {
+		l.Get(200)
+	}
+	if !l.Put(200, 200, 0) || !l.Contains(200) {
+		t.Fatalf("200 should be admitted")
+	}
+
+	// Replacing a cached key is always allowed
+	l.Put(200, "new", 0)
+	if v, _ := l.Peek(200); v != "new" {
+		t.Fatalf("bad value: %v", v)
+	}
+}
+
+func TestLRU_AdmissionPolicyExpired(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(2, 0, nil, WithAdmissionPolicy(true), WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, time.Second)
+	l.Put(2, 2, 0)
+	l.Get(1)
+	l.Get(2)
+	clock.Advance(2 * time.Second)
+	l.Put(3, 3, 0)
+	if !l.Contains(3) || l.Contains(1) || !l.Contains(2) {
+		t.Fatalf("3 should replace the expired entry: %v", l.Keys())
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(64)
+	for i := 0; i < 5; i++ {
+		s.increment("a")
+	}
+	s.increment("b")
+	if a, b := s.estimate("a"), s.estimate("b"); a < 5 || b < 1 || a <= b {
+		t.Fatalf("bad estimates: a=%d b=%d", a, b)
+	}
+	for i := 0; i < 100; i++ {
+		s.increment("a")
+	}
+	if a := s.estimate("a"); a != sketchMaxCount {
+		t.Fatalf("a should saturate: %d", a)
+	}
+	for i := 0; i < s.sampleSize; i++ {
+		s.increment(i + 1000)
+	}
+	if a := s.estimate("a"); a >= sketchMaxCount {
+		t.Fatalf("a should be aged: %d", a)
+	}
+}
+
+// zipfTrace returns n keys drawn from a Zipfian distribution over keys keys
+func zipfTrace(n, keys int) []int {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, uint64(keys-1))
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(z.Uint64())
+	}
+	return trace
+}
+
+func TestLRU_AdmissionPolicyZipfTrace(t *testing.T) {
+	trace := zipfTrace(100000, 10000)
+	a, _ := NewLRUCache(256, Expired, nil, WithAdmissionPolicy(true))
+	l, _ := NewLRUCache(256, Expired, nil)
+	aRatio, lRatio := hitRatio(a, trace), hitRatio(l, trace)
+	if aRatio <= lRatio {
+		t.Fatalf("admission should beat LRU on a Zipfian trace: %v <= %v", aRatio, lRatio)
+	}
+}
+
+func BenchmarkLRU_ZipfTrace(b *testing.B) {
+	trace := zipfTrace(100000, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l, _ := NewLRUCache(256, Expired, nil)
+		b.ReportMetric(hitRatio(l, trace), "hit-ratio")
+	}
+}
+
+func BenchmarkLRU_ZipfTraceAdmission(b *testing.B) {
+	trace := zipfTrace(100000, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l, _ := NewLRUCache(256, Expired, nil, WithAdmissionPolicy(true))
+		b.ReportMetric(hitRatio(l, trace), "hit-ratio")
+	}
+}
@@ -0,0 +1,239 @@
+package lrucache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// twoQRecentRatio is the share of the size kept for entries seen once
+	twoQRecentRatio = 0.25
+	// twoQGhostRatio is the number of evicted keys remembered, relative to the size
+	twoQGhostRatio = 0.5
+)
+
+// TwoQueueCache implements a thread safe fixed size Expire 2Q cache.
+// New entries go to a recent FIFO queue and are promoted to a frequent LRU queue
+// on their second access, so a one-time scan can't flush the frequently used ones.
+// Keys evicted from the recent queue are remembered in a ghost list and go straight
+// to the frequent queue when they are added again.
+type TwoQueueCache struct {
+	size       int
+	recentSize int
+	ghostSize  int
+	recent     *list.List
+	frequent   *list.List
+	ghost      *list.List
+	items      map[interface{}]*list.Element
+	ghostKeys  map[interface{}]*list.Element
+	ttl        time.Duration
+	onEvict    EvictCallback
+	lock       sync.Mutex
+}
+
+// twoQEntry is used to hold a value in the 2Q lists
+type twoQEntry struct {
+	entry
+	frequent bool
+}
+
+// New2QCache creates an expiring 2Q cache with the given size
+func New2QCache(size int, ttl time.Duration, onEvict EvictCallback) (*TwoQueueCache, error) {
+	if size <= 0 {
+		return nil, errNonPositiveSize
+	}
+	recentSize := int(float64(size) * twoQRecentRatio)
+	if recentSize < 1 {
+		recentSize = 1
+	}
+	c := &TwoQueueCache{
+		size:       size,
+		recentSize: recentSize,
+		ghostSize:  int(float64(size) * twoQGhostRatio),
+		recent:     list.New(),
+		frequent:   list.New(),
+		ghost:      list.New(),
+		items:      make(map[interface{}]*list.Element),
+		ghostKeys:  make(map[interface{}]*list.Element),
+		ttl:        ttl,
+		onEvict:    onEvict,
+	}
+	return c, nil
+}
+
+// queue returns the list holding e
+func (c *TwoQueueCache) queue(e *twoQEntry) *list.List {
+	if e.frequent {
+		return c.frequent
+	}
+	return c.recent
+}
+
+// removeElement is used to remove a given list element from the cache
+func (c *TwoQueueCache) removeElement(ent *list.Element) {
+	kv := ent.Value.(*twoQEntry)
+	c.queue(kv).Remove(ent)
+	delete(c.items, kv.key)
+	if c.onEvict != nil {
+		c.onEvict(kv.key, kv.value)
+	}
+}
+
+// access moves an entry hit a second time to the front of the frequent queue
+func (c *TwoQueueCache) access(ent *list.Element) {
+	kv := ent.Value.(*twoQEntry)
+	if kv.frequent {
+		c.frequent.MoveToFront(ent)
+		return
+	}
+	c.recent.Remove(ent)
+	kv.frequent = true
+	c.items[kv.key] = c.frequent.PushFront(kv)
+}
+
+// Get a key's value from the cache.
+func (c *TwoQueueCache) Get(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		if ent.Value.(*twoQEntry).IsExpired() {
+			c.removeElement(ent)
+			return nil, false
+		}
+		value = ent.Value.(*twoQEntry).value
+		c.access(ent)
+		return value, true
+	}
+	return nil, false
+}
+
+// Peek returns a key's value without updating the recent-ness.
+func (c *TwoQueueCache) Peek(key interface{}) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		if ent.Value.(*twoQEntry).IsExpired() {
+			c.removeElement(ent)
+			return nil, false
+		}
+		return ent.Value.(*twoQEntry).value, true
+	}
+	return nil, false
+}
+
+// Put adds the value to the cache at key with the specified maximum duration.
+// It returns true if an eviction occurred.
+func (c *TwoQueueCache) Put(key interface{}, value interface{}, ttl time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	var ex *time.Time
+	if ttl > 0 {
+		expire := time.Now().Add(ttl)
+		ex = &expire
+	}
+	//Check for existing item
+	if ent, ok := c.items[key]; ok {
+		kv := ent.Value.(*twoQEntry)
+		kv.value = value
+		kv.ttl = ex
+		c.access(ent)
+		return false
+	}
+	kv := &twoQEntry{entry: entry{key: key, value: value, ttl: ex}}
+	// a key evicted recently is seen for the second time
+	if g, ok := c.ghostKeys[key]; ok {
+		c.ghost.Remove(g)
+		delete(c.ghostKeys, key)
+		kv.frequent = true
+		c.items[key] = c.frequent.PushFront(kv)
+	} else {
+		c.items[key] = c.recent.PushFront(kv)
+	}
+	if len(c.items) <= c.size {
+		return false
+	}
+	c.evict()
+	return true
+}
+
+// evict removes an entry to make room, from the recent queue if it is over its share
+func (c *TwoQueueCache) evict() {
+	if c.recent.Len() > 0 && (c.recent.Len() > c.recentSize || c.frequent.Len() == 0) {
+		ent := c.recent.Back()
+		key := ent.Value.(*twoQEntry).key
+		c.removeElement(ent)
+		if c.ghostSize > 0 {
+			c.ghostKeys[key] = c.ghost.PushFront(key)
+			if c.ghost.Len() > c.ghostSize {
+				g := c.ghost.Back()
+				c.ghost.Remove(g)
+				delete(c.ghostKeys, g.Value)
+			}
+		}
+		return
+	}
+	c.removeElement(c.frequent.Back())
+}
+
+// Len returns the number of items in the cache.
+func (c *TwoQueueCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.items)
+}
+
+// Remove removes the provided key from the cache.
+func (c *TwoQueueCache) Remove(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		c.removeElement(ent)
+		return true
+	}
+	return false
+}
+
+// Contains Check if a key exsists in cache without updating the recent-ness.
+func (c *TwoQueueCache) Contains(key interface{}) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ent, ok := c.items[key]; ok {
+		return !ent.Value.(*twoQEntry).IsExpired()
+	}
+	return false
+}
+
+// Keys return all the keys in cache, the frequent ones then the recent ones, each from oldest to newest
+func (c *TwoQueueCache) Keys() []interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	keys := make([]interface{}, 0, len(c.items))
+	for _, l := range []*list.List{c.frequent, c.recent} {
+		for ent := l.Back(); ent != nil; ent = ent.Prev() {
+			keys = append(keys, ent.Value.(*twoQEntry).key)
+		}
+	}
+	return keys
+}
+
+// Clear remove all the keys in cache
+func (c *TwoQueueCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for k, ent := range c.items {
+		if c.onEvict != nil {
+			c.onEvict(k, ent.Value.(*twoQEntry).value)
+		}
+		delete(c.items, k)
+	}
+	for k := range c.ghostKeys {
+		delete(c.ghostKeys, k)
+	}
+	c.recent.Init()
+	c.frequent.Init()
+	c.ghost.Init()
+}
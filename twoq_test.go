@@ -0,0 +1,149 @@
+package lrucache
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func Test2QCache(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := New2QCache(16, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		l.Put(i, i, Expired)
+	}
+	if l.Len() != 16 || evictCounter != 16 {
+		t.Fatalf("bad len: %v, evict count: %v", l.Len(), evictCounter)
+	}
+	for i, k := range l.Keys() {
+		if v, ok := l.Peek(k); !ok || v != k || v != i+16 {
+			t.Fatalf("bad key: %v", k)
+		}
+	}
+	if !l.Remove(16) || l.Remove(16) || l.Contains(16) {
+		t.Fatalf("16 should be removed")
+	}
+	l.Put(40, 40, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := l.Get(40); ok {
+		t.Fatalf("40 should be expired")
+	}
+	l.Clear()
+	if l.Len() != 0 || len(l.Keys()) != 0 {
+		t.Fatalf("bad len: %v", l.Len())
+	}
+	if _, err := New2QCache(0, Expired, nil); err == nil {
+		t.Fatalf("should reject a zero size")
+	}
+}
+
+// Test that entries accessed twice survive a scan
+func Test2QCache_ScanResistance(t *testing.T) {
+	l, err := New2QCache(8, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		l.Put(i, i, Expired)
+		l.Get(i)
+	}
+	for i := 100; i < 132; i++ {
+		l.Put(i, i, Expired)
+	}
+	for i := 0; i < 4; i++ {
+		if !l.Contains(i) {
+			t.Fatalf("frequent %v should survive the scan: %v", i, l.Keys())
+		}
+	}
+}
+
+// Test that a key evicted from the recent queue is promoted when added again
+func Test2QCache_Ghost(t *testing.T) {
+	l, err := New2QCache(4, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Put(i, i, Expired)
+	}
+	if l.Contains(0) {
+		t.Fatalf("0 should be evicted")
+	}
+	l.Put(0, 0, Expired)
+	for i := 10; i < 20; i++ {
+		l.Put(i, i, Expired)
+	}
+	if !l.Contains(0) {
+		t.Fatalf("0 should be in the frequent queue: %v", l.Keys())
+	}
+}
+
+// scanTrace returns an access trace alternating a small hot set with one-time scans
+func scanTrace(n int) []int {
+	r := rand.New(rand.NewSource(1))
+	trace := make([]int, 0, n)
+	scan := 1000
+	for len(trace) < n {
+		for i := 0; i < 200; i++ {
+			trace = append(trace, r.Intn(50))
+		}
+		for i := 0; i < 100; i++ {
+			trace = append(trace, scan)
+			scan++
+		}
+	}
+	return trace[:n]
+}
+
+// hitRatio replays trace against a cache, putting the misses
+func hitRatio(l interface {
+	Put(key interface{}, value interface{}, ttl time.Duration) bool
+	Get(key interface{}) (interface{}, bool)
+}, trace []int) float64 {
+	hits := 0
+	for _, k := range trace {
+		if _, ok := l.Get(k); ok {
+			hits++
+		} else {
+			l.Put(k, k, Expired)
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func Test2QCache_ScanTrace(t *testing.T) {
+	trace := scanTrace(30000)
+	q, _ := New2QCache(100, Expired, nil)
+	l, _ := NewLRUCache(100, Expired, nil)
+	qRatio, lRatio := hitRatio(q, trace), hitRatio(l, trace)
+	if qRatio <= lRatio {
+		t.Fatalf("2Q should beat LRU on a scan trace: %v <= %v", qRatio, lRatio)
+	}
+}
+
+func Benchmark2Q_ScanTrace(b *testing.B) {
+	trace := scanTrace(30000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l, _ := New2QCache(100, Expired, nil)
+		b.ReportMetric(hitRatio(l, trace), "hit-ratio")
+	}
+}
+
+func BenchmarkLRU_ScanTrace(b *testing.B) {
+	trace := scanTrace(30000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l, _ := NewLRUCache(100, Expired, nil)
+		b.ReportMetric(hitRatio(l, trace), "hit-ratio")
+	}
+}
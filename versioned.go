@@ -0,0 +1,42 @@
+package lrucache
+
+import (
+	"time"
+)
+
+// SetVersioned adds the value like Put along with its version, unless the key holds a live
+// value with a version greater or equal, so a stale update arriving late can't overwrite
+// a newer one. The values stored without a version, by Put for example, have version 0.
+// It returns true if the value was stored.
+func (c *LruCache) SetVersioned(key, value interface{}, version uint64, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	if ent, ok := c.cache[key]; ok {
+		kv := ent.Value.(*entry)
+		if !kv.miss && !c.expired(kv) && kv.version >= version {
+			return false
+		}
+	}
+	if _, err := c.add(&entry{key: key, value: value, lifetime: c.lifetime(ttl), version: version}); err != nil {
+		return false
+	}
+	// a new key refused by WithAdmissionPolicy is not stored without an error
+	_, ok := c.cache[key]
+	return ok
+}
+
+// Version returns the version of a live key without updating the recent-ness,
+// ok is false if the key is missing, expired or negative.
+func (c *LruCache) Version(key interface{}) (version uint64, ok bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	ent, ok := c.cache[key]
+	if !ok {
+		return 0, false
+	}
+	kv := ent.Value.(*entry)
+	if kv.miss || c.expired(kv) {
+		return 0, false
+	}
+	return kv.version, true
+}
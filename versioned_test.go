@@ -0,0 +1,71 @@
+package lrucache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_SetVersioned(t *testing.T) {
+	clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	l, err := NewLRUCache(4, 0, nil, WithClock(clock))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !l.SetVersioned(1, "v2", 2, 0) {
+		t.Fatalf("v2 should be stored")
+	}
+	if l.SetVersioned(1, "v1", 1, 0) || l.SetVersioned(1, "v2'", 2, 0) {
+		t.Fatalf("older and equal versions should be rejected")
+	}
+	if v, _ := l.Get(1); v != "v2" {
+		t.Fatalf("bad value: %v", v)
+	}
+	if !l.SetVersioned(1, "v3", 3, time.Second) {
+		t.Fatalf("v3 should be stored")
+	}
+	if v, ok := l.Version(1); !ok || v != 3 {
+		t.Fatalf("bad version: %v %v", v, ok)
+	}
+
+	// Update keeps the version, Put resets it
+	l.Update(1, "v3'")
+	if v, _ := l.Version(1); v != 3 {
+		t.Fatalf("bad version: %v", v)
+	}
+	l.Put(2, 2, 0)
+	if v, ok := l.Version(2); !ok || v != 0 {
+		t.Fatalf("bad version: %v %v", v, ok)
+	}
+
+	// an expired or negative entry doesn't hold a version
+	clock.Advance(2 * time.Second)
+	if _, ok := l.Version(1); ok {
+		t.Fatalf("1 should be expired")
+	}
+	if !l.SetVersioned(1, "v1", 1, 0) {
+		t.Fatalf("v1 should replace the expired entry")
+	}
+	l.PutMiss(3, time.Minute)
+	if _, ok := l.Version(3); ok || !l.SetVersioned(3, "v1", 1, 0) {
+		t.Fatalf("v1 should replace the negative entry")
+	}
+}
+
+func TestLRU_SetVersionedRefused(t *testing.T) {
+	l, err := NewLRUCache(2, Expired, nil, WithAdmissionPolicy(true))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, Expired)
+	l.Put(2, 2, Expired)
+	for i := 0; i < 4; i++ {
+		l.Get(1)
+		l.Get(2)
+	}
+	if l.SetVersioned(3, 3, 1, Expired) || l.Contains(3) {
+		t.Fatalf("a refused key should not be reported as stored")
+	}
+	if !l.SetVersioned(1, 10, 1, Expired) {
+		t.Fatalf("1 should be updated")
+	}
+}
@@ -0,0 +1,24 @@
+package lrucache
+
+import (
+	"time"
+)
+
+// PutWeighted adds the value like Put with an entry taking weight slots toward the
+// cache size instead of one, the oldest entries are evicted until the total weight fits.
+// A weight <= 0 counts as 1. A value heavier than the cache size is never cached,
+// PutWeighted drops it and removes any previous value stored at its key.
+// It returns true if an eviction occurred.
+func (c *LruCache) PutWeighted(key, value interface{}, weight int, ttl time.Duration) bool {
+	c.writeLock()
+	defer c.writeUnlock()
+	return c.set(&entry{key: key, value: value, lifetime: c.lifetime(ttl), weight: weight}) > 0
+}
+
+// Weight returns the total weight of the entries in the cache, which is their number
+// unless some of them were stored by PutWeighted.
+func (c *LruCache) Weight() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.weight
+}
@@ -0,0 +1,75 @@
+package lrucache
+
+import (
+	"testing"
+)
+
+func TestLRU_PutWeighted(t *testing.T) {
+	evictCounter := 0
+	onEvicted := func(k interface{}, v interface{}) {
+		evictCounter += 1
+	}
+	l, err := NewLRUCache(10, Expired, onEvicted)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	l.Put(1, 1, Expired)
+	l.PutWeighted(2, 2, 4, Expired)
+	l.PutWeighted(3, 3, 4, Expired)
+	if l.Weight() != 9 || l.Len() != 3 {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+
+	// 1 and 2 make room
+	if !l.PutWeighted(4, 4, 5, Expired) {
+		t.Fatalf("should evict")
+	}
+	if l.Weight() != 9 || evictCounter != 2 || l.Contains(2) {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+
+	// updating 3 adjusts the total by the delta
+	l.PutWeighted(3, 3, 2, Expired)
+	if l.Weight() != 7 {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+	if v, ok := l.Get(3); !ok || v != 3 {
+		t.Fatalf("bad value: %v", v)
+	}
+	l.Update(3, 30)
+	if l.Weight() != 7 {
+		t.Fatalf("update should keep the weight: %v", l.Weight())
+	}
+
+	// too heavy to fit, the previous value is removed
+	if l.PutWeighted(4, 40, 11, Expired) || l.Contains(4) {
+		t.Fatalf("should not be cached")
+	}
+	if l.Weight() != 2 {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+
+	l.Remove(3)
+	if l.Weight() != 0 {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+}
+
+func TestLRU_ResizeWeighted(t *testing.T) {
+	l, err := NewLRUCache(10, Expired, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.PutWeighted(1, 1, 3, Expired)
+	l.PutWeighted(2, 2, 3, Expired)
+	l.PutWeighted(3, 3, 3, Expired)
+
+	if evicted, _ := l.Resize(5); evicted != 2 || l.Weight() != 3 || !l.Contains(3) {
+		t.Fatalf("bad resize: %v", evicted)
+	}
+	l.Clear()
+	if l.Weight() != 0 {
+		t.Fatalf("bad weight: %v", l.Weight())
+	}
+}
@@ -0,0 +1,183 @@
+package lrucache
+
+import (
+	"sync"
+	"time"
+)
+
+// writeBehind queues the values inserted in the cache and flushes them to the backing store
+type writeBehind struct {
+	flush    func(batch []KV) error
+	maxBatch int
+
+	// pending holds the latest value of each dirty key in insertion order, guarded by lock
+	lock    sync.Mutex
+	pending []KV
+	index   map[interface{}]int
+
+	// flushLock serializes the flushes
+	flushLock sync.Mutex
+	kick      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// WithWriteBehind makes the cache queue the values it stores and write them to a backing store
+// in the background with flush, every interval or as soon as maxBatch values are queued.
+// Each batch holds at most maxBatch entries, a maxBatch <= 0 flushes everything in one batch.
+// Only the latest value of a key is written. The values loaded by WithLoader or GetOrLoad are
+// not written back, nor the negative entries. A batch for which flush returns an error is
+// queued again ahead of the values stored since and retried on the next flush.
+// Call Close to flush the queued values and stop the background goroutine.
+func WithWriteBehind(flush func(batch []KV) error, interval time.Duration, maxBatch int) Option {
+	return func(c *LruCache) {
+		w := &writeBehind{
+			flush:    flush,
+			maxBatch: maxBatch,
+			index:    make(map[interface{}]int),
+			kick:     make(chan struct{}, 1),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		c.writeBehind = w
+		go w.run(interval)
+	}
+}
+
+// run flushes every interval or when kicked until stopped, with a last flush on stop
+func (w *writeBehind) run(interval time.Duration) {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			w.flushPending()
+		case <-w.kick:
+			w.flushPending()
+		case <-w.stop:
+			w.closeErr = w.flushPending()
+			return
+		}
+	}
+}
+
+// queue adds kv to the pending values, replacing a pending value of the same key
+func (w *writeBehind) queue(kv KV) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if i, ok := w.index[kv.Key]; ok {
+		w.pending[i] = kv
+		return
+	}
+	w.index[kv.Key] = len(w.pending)
+	w.pending = append(w.pending, kv)
+	if w.maxBatch > 0 && len(w.pending) >= w.maxBatch {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take removes and returns up to maxBatch pending values
+func (w *writeBehind) take() []KV {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n := len(w.pending)
+	if w.maxBatch > 0 && n > w.maxBatch {
+		n = w.maxBatch
+	}
+	batch := w.pending[:n:n]
+	w.pending = w.pending[n:]
+	w.index = make(map[interface{}]int, len(w.pending))
+	for i, kv := range w.pending {
+		w.index[kv.Key] = i
+	}
+	return batch
+}
+
+// requeue puts a failed batch back in front of the pending values, dropping the values
+// superseded by a newer one
+func (w *writeBehind) requeue(batch []KV) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	pending := make([]KV, 0, len(batch)+len(w.pending))
+	for _, kv := range batch {
+		if _, ok := w.index[kv.Key]; !ok {
+			pending = append(pending, kv)
+		}
+	}
+	pending = append(pending, w.pending...)
+	w.pending = pending
+	w.index = make(map[interface{}]int, len(pending))
+	for i, kv := range pending {
+		w.index[kv.Key] = i
+	}
+}
+
+// flushPending writes the pending values batch by batch, stopping at the first error
+func (w *writeBehind) flushPending() error {
+	w.flushLock.Lock()
+	defer w.flushLock.Unlock()
+	for {
+		batch := w.take()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := w.flush(batch); err != nil {
+			w.requeue(batch)
+			return err
+		}
+	}
+}
+
+// queueWrite queues e to the write-behind if it is a new value, the write lock must be held
+func (c *LruCache) queueWrite(e *entry) {
+	if c.writeBehind == nil || e.miss || e.stored {
+		return
+	}
+	ttl := e.lifetime
+	if ttl <= 0 {
+		ttl = NoExpiration
+	}
+	c.writeBehind.queue(KV{Key: e.key, Value: e.value, TTL: ttl})
+}
+
+// Flush writes the values queued by the write-behind to the backing store right away,
+// returning the error of the failed batch. It does nothing without WithWriteBehind.
+func (c *LruCache) Flush() error {
+	if c.writeBehind == nil {
+		return nil
+	}
+	return c.writeBehind.flushPending()
+}
+
+// Close flushes the values queued by the write-behind and stops its background goroutine,
+// returning the error of the last flush. The values stored after Close are only written
+// by Flush. It also stops the resizing of WithAutoTune and the workers of WithEvictWorkers,
+// once their queued callbacks returned. It does nothing without these options, and is safe
+// to call more than once.
+func (c *LruCache) Close() error {
+	if c.autoTune != nil {
+		c.autoTune.close()
+	}
+	if c.evictWorkers != nil {
+		c.evictWorkers.close()
+	}
+	w := c.writeBehind
+	if w == nil {
+		return nil
+	}
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+	return w.closeErr
+}
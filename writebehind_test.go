@@ -0,0 +1,112 @@
+package lrucache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// store records the batches written by the write-behind
+type store struct {
+	lock    sync.Mutex
+	batches [][]KV
+	fail    bool
+}
+
+func (s *store) flush(batch []KV) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.fail {
+		return errors.New("store down")
+	}
+	s.batches = append(s.batches, append([]KV(nil), batch...))
+	return nil
+}
+
+func (s *store) written() [][]KV {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.batches
+}
+
+func TestLRU_WriteBehind(t *testing.T) {
+	st := &store{}
+	l, err := NewLRUCache(16, 0, nil, WithWriteBehind(st.flush, time.Hour, 3))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	l.Put(1, 1, 0)
+	l.Put(2, 2, Expired)
+	l.Put(1, 10, 0)
+	l.PutMiss(4, Expired)
+	if len(st.written()) != 0 {
+		t.Fatalf("should not flush yet: %v", st.written())
+	}
+	l.Put(3, 3, 0)
+	waitFor(t, func() bool { return len(st.written()) == 1 })
+	want := []KV{{Key: 1, Value: 10, TTL: NoExpiration}, {Key: 2, Value: 2, TTL: Expired}, {Key: 3, Value: 3, TTL: NoExpiration}}
+	if b := st.written()[0]; len(b) != 3 || b[0] != want[0] || b[1] != want[1] || b[2] != want[2] {
+		t.Fatalf("bad batch: %v", b)
+	}
+
+	l.Put(5, 5, 0)
+	if err := l.Flush(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b := st.written(); len(b) != 2 || len(b[1]) != 1 || b[1][0].Key != 5 {
+		t.Fatalf("bad batches: %v", b)
+	}
+	if err := l.Flush(); err != nil || len(st.written()) != 2 {
+		t.Fatalf("nothing should be flushed: %v", err)
+	}
+}
+
+func TestLRU_WriteBehindRetry(t *testing.T) {
+	st := &store{fail: true}
+	l, err := NewLRUCache(16, 0, nil, WithWriteBehind(st.flush, time.Hour, 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	l.Put(1, 1, 0)
+	l.Put(2, 2, 0)
+	if err := l.Flush(); err == nil {
+		t.Fatalf("flush should fail")
+	}
+	l.Put(1, 10, 0)
+
+	st.lock.Lock()
+	st.fail = false
+	st.lock.Unlock()
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b := st.written()
+	if len(b) != 1 || len(b[0]) != 2 || b[0][0].Value != 10 || b[0][1].Key != 2 {
+		t.Fatalf("failed batch should be retried with the newer values: %v", b)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestLRU_WriteBehindInterval(t *testing.T) {
+	st := &store{}
+	loader := LoaderFunc(func(key interface{}) (interface{}, time.Duration, error) {
+		return key, 0, nil
+	})
+	l, err := NewLRUCache(16, 0, nil, WithWriteBehind(st.flush, 10*time.Millisecond, 100), WithLoader(loader))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer l.Close()
+
+	l.Get(1)
+	l.Put(2, 2, 0)
+	waitFor(t, func() bool { return len(st.written()) == 1 })
+	if b := st.written()[0]; len(b) != 1 || b[0].Key != 2 {
+		t.Fatalf("loaded values should not be written back: %v", b)
+	}
+}